@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/ZnNr/WB-test-L0/internal/auth"
+	"github.com/ZnNr/WB-test-L0/internal/broker"
 	"github.com/ZnNr/WB-test-L0/internal/cache"
 	"github.com/ZnNr/WB-test-L0/internal/consumer"
 	"github.com/ZnNr/WB-test-L0/internal/controller/server"
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/ZnNr/WB-test-L0/internal/events"
+	"github.com/ZnNr/WB-test-L0/internal/health"
+	"github.com/ZnNr/WB-test-L0/internal/lifecycle"
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+	"github.com/ZnNr/WB-test-L0/internal/models"
 	"github.com/ZnNr/WB-test-L0/internal/repository"
 	"github.com/ZnNr/WB-test-L0/internal/repository/config"
 	"github.com/ZnNr/WB-test-L0/migration"
 	"go.uber.org/zap"
 	"log"
-	"os"
-	"os/signal"
 	"sync"
 )
 
@@ -19,28 +27,59 @@ var (
 	cfgPath = "config/config.yaml"
 )
 
+// errCacheFull останавливает прогрев кэша, когда в него больше не помещаются заказы
+var errCacheFull = errors.New("cache is full")
+
 func main() {
 	logger := initializeLogger()
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	cfg := loadConfig(cfgPath, logger)
+	cfg := loadConfig(config.Path(cfgPath), logger)
+
+	storage := initializeStorage(cfg, logger)
+	ordersRepo := storage.Orders
+	migrateDatabase(cfg, storage, logger)
+	appCache := initializeCache(cfg, logger)
+	messageBroker := initializeBroker(cfg, logger)
+	deadLetters := initializeDeadLetterQueue(cfg, messageBroker, storage, logger)
+
+	orders := cache.NewReadThrough(appCache, ordersRepo, cfg.Cache.NegativeTTL)
 
-	ordersRepo := initializeRepository(cfg, logger)
-	defer closeRepository(ordersRepo, logger)
-	migration.InitializeDatabaseSchema(ordersRepo.DB, logger)
-	appCache := initializeCache(ordersRepo, logger)
+	consumerStatus := consumer.NewStatus()
+	warmup := &health.Flag{}
+	checker := initializeHealth(cfg, storage, consumerStatus, warmup)
 
-	server := initializeController(cfgPath, appCache, logger)
+	authenticator := initializeAuth(cfg, logger)
+	publisher := initializeEvents(cfg, messageBroker, logger)
+	server := initializeController(cfg, appCache, orders, ordersRepo, deadLetters, checker, authenticator, publisher, logger)
 	startServer(server, logger)
 
-	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, os.Interrupt)
+	// Прогрев идёт в фоне, /readyz сообщает о его завершении
+	warmupCtx, stopWarmup := context.WithCancel(context.Background())
+	go warmUpCache(warmupCtx, cfg, appCache, ordersRepo, warmup, logger)
 
-	subscribeToKafka(appCache, ordersRepo, logger, sigchan)
+	stopConsumer := subscribeToBroker(cfg.Kafka, messageBroker, appCache, orders, ordersRepo, deadLetters, consumerStatus, logger)
+
+	// Порядок остановки: сначала перестаём читать брокер и дожидаемся обработки
+	// текущих сообщений с коммитом смещений, затем закрываем HTTP-сервер,
+	// и только после этого — брокер и соединение с БД, которыми они пользуются
+	shutdown := lifecycle.New(cfg.App.ShutdownTimeout, logger)
+	shutdown.OnShutdown("cache warm-up", func(ctx context.Context) error { stopWarmup(); return nil })
+	shutdown.OnShutdown("consumer", stopConsumer)
+	shutdown.OnShutdown("http server", server.Shutdown)
+	shutdown.OnShutdown("message broker", func(ctx context.Context) error { return messageBroker.Close() })
+	shutdown.OnShutdown("repository", func(ctx context.Context) error { return storage.Close() })
+
+	sig := shutdown.WaitForSignal()
+	logger.Info("Received signal, shutting down...", zap.String("signal", sig.String()))
 
-	logger.Info("Application shutting down")
+	if err := shutdown.Shutdown(); err != nil {
+		logger.Error("Application shut down with errors", zap.Error(err))
+		return
+	}
+	logger.Info("Application shut down gracefully")
 }
 
 func initializeLogger() *zap.Logger {
@@ -60,50 +99,156 @@ func loadConfig(cfgPath string, logger *zap.Logger) *config.Config {
 	return cfg
 }
 
-func initializeRepository(cfg *config.Config, logger *zap.Logger) *repository.OrdersRepo {
-	ordersRepo, err := repository.New(cfg)
+// initializeStorage открывает хранилище, выбранное в настройках storage.type
+func initializeStorage(cfg *config.Config, logger *zap.Logger) *repository.Storage {
+	storage, err := repository.Open(cfg)
 	if err != nil {
 		logger.Fatal("Failed to initialize repository", zap.Error(err))
 	}
+	if storage.DB == nil {
+		logger.Warn("Repository initialized in memory, orders are lost on restart")
+		return storage
+	}
 	logger.Info("Repository initialized successfully",
 		zap.String("host", cfg.DB.Host),
 		zap.String("port", cfg.DB.Port),
 		zap.String("db", cfg.DB.Name),
 		zap.String("user", cfg.DB.User),
 	)
-	return ordersRepo
+	return storage
 }
 
-func closeRepository(repo *repository.OrdersRepo, logger *zap.Logger) {
-	if err := repo.DB.Close(); err != nil {
-		logger.Error("Error closing repository", zap.Error(err))
-	} else {
-		logger.Info("Repository closed successfully")
+// migrateDatabase применяет недостающие миграции, если это разрешено конфигурацией.
+// Иначе схема обновляется отдельно командой migrate up. Хранилищу в памяти миграции не нужны.
+func migrateDatabase(cfg *config.Config, storage *repository.Storage, logger *zap.Logger) {
+	if storage.DB == nil {
+		return
+	}
+	if !cfg.DB.AutoMigrate {
+		logger.Info("Automatic migrations disabled")
+		return
 	}
-}
-
-func initializeCache(ordersRepo *repository.OrdersRepo, logger *zap.Logger) *cache.Cache {
-	appCache := cache.New(100)
 
-	orders, err := ordersRepo.GetOrders()
+	migrator, err := migration.New(storage.DB, logger)
 	if err != nil {
-		logger.Fatal("Orders Load error", zap.Error(err))
+		logger.Fatal("Failed to load migrations", zap.Error(err))
 	}
+	if err := migrator.Up(context.Background()); err != nil {
+		logger.Fatal("Failed to migrate database", zap.Error(err))
+	}
+}
 
-	logger.Info("Cache initialized successfully")
-	for _, order := range orders {
-		appCache.SaveOrder(order)
-		logger.Info("order cached successfully", zap.String("order_uid", order.OrderUID))
+func initializeCache(cfg *config.Config, logger *zap.Logger) *cache.Cache {
+	appCache, err := cache.NewBounded(cache.Options{
+		MaxEntries: cfg.Cache.MaxEntries,
+		MaxBytes:   cfg.Cache.MaxBytes,
+		Policy:     cache.Policy(cfg.Cache.Policy),
+	})
+	if err != nil {
+		logger.Fatal("Cache initialization error", zap.Error(err))
 	}
 
+	metrics.RegisterCache(metrics.CacheStats{
+		Entries:   func() float64 { return float64(appCache.Stats().Entries) },
+		Bytes:     func() float64 { return float64(appCache.Stats().Bytes) },
+		Hits:      func() float64 { return float64(appCache.Stats().Hits) },
+		Misses:    func() float64 { return float64(appCache.Stats().Misses) },
+		Evictions: func() float64 { return float64(appCache.Stats().Evictions) },
+	})
 	return appCache
 }
 
-func initializeController(cfgPath string, cache *cache.Cache, logger *zap.Logger) *server.Server {
-	server, err := server.New(cfgPath, cache)
+// warmUpCache загружает заказы из БД в кэш и отмечает завершение прогрева в warmup.
+// Отмена ctx прерывает загрузку текущей пачки.
+func warmUpCache(ctx context.Context, cfg *config.Config, appCache *cache.Cache, ordersRepo repository.Orders, warmup *health.Flag, logger *zap.Logger) {
+	// Заказы загружаются пачками; когда кэш заполнен, загрузка прекращается,
+	// остальные заказы подгрузятся при обращении через read-through слой
+	warmed := 0
+	err := ordersRepo.StreamOrders(ctx, cfg.Cache.WarmupBatchSize, func(batch []models.Order) error {
+		for _, order := range batch {
+			appCache.SaveOrder(order)
+		}
+		warmed += len(batch)
+		logger.Info("Orders batch cached successfully", zap.Int("batch", len(batch)), zap.Int("total", warmed))
+
+		if appCache.Stats().Evictions > 0 {
+			return errCacheFull
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errCacheFull) {
+		logger.Error("Orders Load error", zap.Error(err))
+		warmup.Done(fmt.Errorf("cache warm-up failed: %w", err))
+		return
+	}
+
+	stats := appCache.Stats()
+	logger.Info("Cache initialized successfully",
+		zap.Int("entries", stats.Entries),
+		zap.Int64("bytes", stats.Bytes),
+		zap.Uint64("evictions", stats.Evictions),
+	)
+	warmup.Done(nil)
+}
+
+// initializeHealth регистрирует проверки зависимостей для /readyz.
+func initializeHealth(cfg *config.Config, storage *repository.Storage, consumerStatus *consumer.Status, warmup *health.Flag) *health.Checker {
+	checker := health.New(cfg.App.HealthCheckTimeout)
+	if storage.DB != nil {
+		checker.Register("postgres", storage.DB.PingContext)
+	}
+	checker.Register("kafka_consumer", consumerStatus.Check)
+	checker.Register("cache_warmup", warmup.Check)
+	return checker
+}
+
+// initializeBroker подключает брокер сообщений, выбранный в настройках.
+// Через него читаются заказы и публикуются карантин и события.
+func initializeBroker(cfg *config.Config, logger *zap.Logger) broker.Broker {
+	messageBroker, err := broker.Open(cfg)
+	if err != nil {
+		logger.Fatal("Message broker initialization error", zap.Error(err))
+	}
+	logger.Info("Message broker initialized successfully", zap.String("type", cfg.Broker.Type))
+	return messageBroker
+}
+
+func initializeDeadLetterQueue(cfg *config.Config, messageBroker broker.Broker, storage *repository.Storage, logger *zap.Logger) *dlq.Queue {
+	deadLetters := dlq.New(cfg.Kafka, messageBroker, storage.DeadLetters)
+	logger.Info("Dead letter queue initialized successfully", zap.String("topic", cfg.Kafka.DLQTopic))
+	return deadLetters
+}
+
+// initializeAuth создаёт проверку доступа к API; nil означает, что аутентификация отключена
+// и без auth.insecure_allow_anonymous доступно только чтение.
+func initializeAuth(cfg *config.Config, logger *zap.Logger) *auth.Authenticator {
+	if !cfg.Auth.Enabled {
+		if cfg.Auth.InsecureAllowAnonymous {
+			logger.Warn("Authentication disabled and anonymous access allowed, operator and admin routes are open to anyone who can reach the port")
+		} else {
+			logger.Warn("Authentication disabled, operator and admin routes are rejected")
+		}
+		return nil
+	}
+	authenticator, err := auth.New(cfg.Auth, logger.Named("audit"))
 	if err != nil {
-		logger.Fatal("Controller initialization error", zap.Error(err))
+		logger.Fatal("Auth initialization error", zap.Error(err))
 	}
+	logger.Info("Authentication enabled",
+		zap.Int("api_keys", len(cfg.Auth.APIKeys)),
+		zap.Bool("jwt", cfg.Auth.JWTSecret != ""),
+	)
+	return authenticator
+}
+
+func initializeEvents(cfg *config.Config, messageBroker broker.Broker, logger *zap.Logger) *events.BrokerPublisher {
+	publisher := events.NewBrokerPublisher(cfg.Kafka, messageBroker, logger)
+	logger.Info("Events publisher initialized successfully", zap.String("topic", cfg.Kafka.EventsTopic))
+	return publisher
+}
+
+func initializeController(cfg *config.Config, appCache *cache.Cache, orders *cache.ReadThrough, ordersRepo repository.Orders, deadLetters *dlq.Queue, checker *health.Checker, authenticator *auth.Authenticator, publisher events.Publisher, logger *zap.Logger) *server.Server {
+	server := server.New(cfg, appCache, orders, ordersRepo, deadLetters, checker, authenticator, publisher)
 	logger.Info("Controller initialized successfully")
 	return server
 }
@@ -119,29 +264,23 @@ func startServer(server *server.Server, logger *zap.Logger) {
 	logger.Info("Server started successfully")
 }
 
-func subscribeToKafka(cache *cache.Cache, repo *repository.OrdersRepo, logger *zap.Logger, sigchan chan os.Signal) {
-	// Создаем контекст с отменой, чтобы корректно завершать работу
+// subscribeToBroker запускает консьюмер в фоне и возвращает функцию его остановки.
+// Остановка отменяет контекст и ждёт, пока консьюмер обработает текущие сообщения
+// и закоммитит смещения.
+func subscribeToBroker(cfg config.KafkaConfig, messageBroker broker.Broker, cache *cache.Cache, orders *cache.ReadThrough, repo repository.Orders, deadLetters *dlq.Queue, status *consumer.Status, logger *zap.Logger) lifecycle.StopFunc {
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 
 	var wg sync.WaitGroup
-	wg.Add(1) // Добавляем в группу ожидания
+	wg.Add(1) // Subscribe вызывает wg.Done при завершении
 
 	go func() {
-		defer wg.Done() // Убедимся, что wait group завершится
-
-		if err := consumer.Subscribe(ctx, cache, repo, logger, &wg); err != nil {
+		if err := consumer.Subscribe(ctx, cfg, messageBroker, cache, orders, repo, deadLetters, status, logger, &wg); err != nil {
 			logger.Error("Consumer error", zap.Error(err))
-		} else {
-			logger.Info("Consumer started successfully")
 		}
 	}()
 
-	// Обрабатываем системные сигналы и завершаем работу при их получении
-	sig := <-sigchan
-	logger.Info("Received signal, shutting down...", zap.String("signal", sig.String()))
-	cancel() // Отменяем контекст, что инициирует завершение работы consumer
-
-	wg.Wait() // Ждем завершения работы go-рутины
-	logger.Info("Consumer shut down gracefully")
+	return func(stopCtx context.Context) error {
+		cancel()
+		return lifecycle.Wait(stopCtx, &wg)
+	}
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/ZnNr/WB-test-L0/internal/repository"
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+	"github.com/ZnNr/WB-test-L0/migration"
+	"go.uber.org/zap"
+)
+
+var (
+	cfgPath = "config/config.yaml"
+)
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  up          apply all pending migrations
+  down [N]    roll back the last N applied migrations (default 1)
+  status      show applied and pending migrations`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	cfg, err := config.Load(config.Path(cfgPath))
+	if err != nil {
+		logger.Fatal("Failed to load config", zap.Error(err))
+	}
+	if cfg.Storage.Type != repository.StoragePostgres {
+		logger.Fatal("Migrations apply only to postgres storage", zap.String("storage", cfg.Storage.Type))
+	}
+
+	ordersRepo, err := repository.New(cfg)
+	if err != nil {
+		logger.Fatal("Connection to DB failed", zap.Error(err))
+	}
+	defer ordersRepo.DB.Close()
+
+	migrator, err := migration.New(ordersRepo.DB, logger)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", zap.Error(err))
+	}
+
+	ctx := context.Background()
+	switch os.Args[1] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			steps, err = strconv.Atoi(os.Args[2])
+			if err != nil || steps < 1 {
+				logger.Fatal("Number of steps must be a positive number", zap.String("steps", os.Args[2]))
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case "status":
+		err = printStatus(ctx, migrator)
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		logger.Fatal("Migration command failed", zap.String("command", os.Args[1]), zap.Error(err))
+	}
+}
+
+func printStatus(ctx context.Context, migrator *migration.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+		}
+		fmt.Printf("%04d  %-30s  %s\n", status.Version, status.Name, applied)
+	}
+	return nil
+}
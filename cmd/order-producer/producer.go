@@ -1,14 +1,17 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"github.com/IBM/sarama"
+	"github.com/ZnNr/WB-test-L0/internal/broker"
+	"github.com/ZnNr/WB-test-L0/internal/envelope"
+	"github.com/ZnNr/WB-test-L0/internal/models"
 	"github.com/ZnNr/WB-test-L0/internal/order_gen"
 	"github.com/ZnNr/WB-test-L0/internal/repository"
 	"github.com/ZnNr/WB-test-L0/internal/repository/config"
 	"log"
 	"strconv"
+	"time"
 )
 
 var (
@@ -16,52 +19,44 @@ var (
 )
 
 func main() {
-	topic := "orders"
-
 	// Загружаем конфигурацию
-	cfg, err := config.Load(cfgPath)
+	cfg, err := config.Load(config.Path(cfgPath))
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
 	// Подключаемся к базе данных
-	ordersRepo, err := repository.New(cfg)
-	if err != nil {
-		log.Fatalf("Connection to DB failed: %v", err)
-	}
-	defer func() {
-		if err := ordersRepo.DB.Close(); err != nil {
-			log.Fatalf("Failed to close database connection: %v", err)
-		}
-	}()
-	brokers := cfg.Kafka.Brokers // Предполагаем, что конфигурация содержит список брокеров
-	producer, err := ConnectProducer(brokers)
+	ordersRepo, closeRepo := connectRepository(cfg)
+	defer closeRepo()
+	// Подключаемся к брокеру сообщений, выбранному в настройках
+	producer, err := broker.Open(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to Kafka: %v", err)
+		log.Fatalf("Failed to connect to message broker: %v", err)
 	}
 	defer func() {
 		if err := producer.Close(); err != nil {
-			log.Fatalf("Failed to close Kafka producer: %v", err)
+			log.Fatalf("Failed to close message broker: %v", err)
 		}
 	}()
 
 	log.Println("Producer is launched!")
 	// Получаем старые заказы
-	orders, err := ordersRepo.GetOrders()
+	orders, err := ordersRepo.GetOrders(context.Background())
 	if err != nil {
 		log.Fatalf("Failed to get old orders from DB: %v", err)
 	}
 	// Логи загруженных заказов
 	log.Printf("Loaded %d orders from the database", len(orders))
-	// Подключаемся к Kafka
 
 	// Основной цикл ввода
 	for {
 		log.Println("Type 's' to generate a new order")
 		log.Println("Type 'c' to select and send a copy of an existing order")
+		log.Println("Type 'u' to change the status of an order by its order_uid")
+		log.Println("Type 'd' to delete an order by its order_uid")
 		log.Println("Type 'exit' to quit the program")
 		var input string
-		var orderJSON []byte
+		var msg *broker.Message
 		fmt.Scanln(&input)
 
 		if input == "exit" {
@@ -71,11 +66,12 @@ func main() {
 
 		if input == "s" {
 			orderGenerated := order_gen.GenerateOrder()
-			orderJSON, err = json.Marshal(orderGenerated)
+			event, err := envelope.New(envelope.TypeOrderCreated, orderGenerated.OrderUID, orderGenerated)
 			if err != nil {
 				log.Printf("Failed to convert order to JSON: %s", err)
 				continue
 			}
+			msg = event.Binary(cfg.Kafka.Topic)
 		}
 
 		if input == "c" {
@@ -94,20 +90,58 @@ func main() {
 				log.Println("Entered is not a number!")
 				continue
 			}
-			if ind < 0 || ind > len(orders) {
+			if ind < 0 || ind >= len(orders) {
 				log.Println("Entered number isn't in range of orders!")
 				continue
 			}
-			orderJSON, err = json.Marshal(orders[ind])
+			// Копия без версии: консьюмер сохранит её следующей версией после сохранённой
+			order := orders[ind]
+			order.Version = 0
+			event, err := envelope.New(envelope.TypeOrderUpdated, order.OrderUID, order)
 			if err != nil {
 				log.Printf("Failed to convert order to JSON: %s", err)
 				continue
 			}
+			msg = event.Binary(cfg.Kafka.Topic)
+		}
+
+		if input == "u" {
+			log.Println("Enter order_uid and new status of the order:")
+			var orderUID, statusStr string
+			fmt.Scanln(&orderUID, &statusStr)
+			status, err := models.ParseOrderStatus(statusStr)
+			if orderUID == "" || err != nil {
+				log.Printf("Expected order_uid and one of statuses %v", models.OrderStatuses())
+				continue
+			}
+			event, err := envelope.New(envelope.TypeOrderStatusChanged, orderUID, models.StatusChange{Status: status, ChangedAt: time.Now().UTC()})
+			if err != nil {
+				log.Printf("Failed to convert status change to JSON: %s", err)
+				continue
+			}
+			msg = event.Binary(cfg.Kafka.Topic)
+		}
+
+		if input == "d" {
+			log.Println("Enter order_uid of the order to delete:")
+			var orderUID string
+			fmt.Scanln(&orderUID)
+			if orderUID == "" {
+				log.Println("order_uid must not be empty!")
+				continue
+			}
+			// Пустое значение — tombstone: консьюмер удалит заказ с этим ключом
+			msg = &broker.Message{Topic: cfg.Kafka.Topic, Key: []byte(orderUID)}
+		}
+
+		if msg == nil {
+			log.Println("Unknown command!")
+			continue
 		}
 
-		err = PushOrderToQueue(producer, topic, orderJSON)
+		err = PushOrderToQueue(context.Background(), producer, msg)
 		if err != nil {
-			log.Printf("Failed to send message to Kafka: %s", err)
+			log.Printf("Failed to send message to broker: %s", err)
 			continue
 		}
 
@@ -115,29 +149,33 @@ func main() {
 	}
 }
 
-func ConnectProducer(brokers []string) (sarama.SyncProducer, error) {
-	config := sarama.NewConfig()
-	config.Producer.Return.Successes = true
-	config.Producer.RequiredAcks = sarama.WaitForAll
-
-	return sarama.NewSyncProducer(brokers, config)
-}
-
-func PushOrderToQueue(producer sarama.SyncProducer, topic string, message []byte) error {
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.StringEncoder(message),
+// connectRepository открывает хранилище, выбранное в настройках storage.type.
+// С хранилищем memory старых заказов для копирования нет, но новые заказы отправлять можно.
+// Возвращает функцию закрытия хранилища.
+func connectRepository(cfg *config.Config) (repository.Orders, func()) {
+	storage, err := repository.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to storage %q: %v", cfg.Storage.Type, err)
 	}
+	return storage.Orders, func() {
+		if err := storage.Close(); err != nil {
+			log.Fatalf("Failed to close database connection: %v", err)
+		}
+	}
+}
 
-	partition, offset, err := producer.SendMessage(msg)
-	if err != nil {
+// PushOrderToQueue отправляет сообщение о заказе в топик брокера. Ключ сообщения — order_uid,
+// чтобы в сжатом (compacted) топике оставалась последняя версия каждого заказа;
+// сообщение без значения — tombstone, который удаляет заказ.
+func PushOrderToQueue(ctx context.Context, producer broker.Publisher, msg *broker.Message) error {
+	if err := producer.Publish(ctx, msg); err != nil {
 		return err
 	}
 
 	log.Printf("Order is stored in topic(%s)/partition(%d)/offset(%d)\n",
-		topic,
-		partition,
-		offset,
+		msg.Topic,
+		msg.Partition,
+		msg.Offset,
 	)
 
 	return nil
@@ -1,75 +1,89 @@
 package main
 
 import (
-	"github.com/IBM/sarama"
-
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/broker"
+	"github.com/ZnNr/WB-test-L0/internal/envelope"
+	"github.com/ZnNr/WB-test-L0/internal/order_gen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-// Successfully connect to a Kafka broker using valid broker addresses
-func TestConnectProducerWithValidBrokers(t *testing.T) {
+// Pushes an order.created event to the in-memory broker topic
+func TestPushOrderToQueueWithMemoryBroker(t *testing.T) {
 	// Arrange
-	brokers := []string{"localhost:9092"}
+	producer := broker.NewMemory()
+	order := order_gen.GenerateOrder()
+	event, err := envelope.New(envelope.TypeOrderCreated, order.OrderUID, order)
+	require.NoError(t, err)
 
 	// Act
-	producer, err := ConnectProducer(brokers)
+	err = PushOrderToQueue(context.Background(), producer, event.Binary("orders"))
 
 	// Assert
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
-	}
-	if producer == nil {
-		t.Fatal("Expected a valid producer, got nil")
-	}
+	require.NoError(t, err)
+	messages := producer.Messages("orders")
+	require.Len(t, messages, 1)
+	assert.Equal(t, order.OrderUID, string(messages[0].Key))
+	decoded, err := envelope.Decode(&messages[0])
+	require.NoError(t, err)
+	assert.Equal(t, envelope.TypeOrderCreated, decoded.Type)
+	assert.Equal(t, order.OrderUID, decoded.OrderUID())
 }
 
-// Return a SyncProducer instance when connection is successful
-func TestReturnSyncProducerInstanceOnSuccess(t *testing.T) {
+// Sends an empty message keyed by order_uid as a tombstone
+func TestPushOrderToQueueSendsTombstone(t *testing.T) {
 	// Arrange
-	brokers := []string{"localhost:9092"}
+	producer := broker.NewMemory()
 
 	// Act
-	producer, err := ConnectProducer(brokers)
+	err := PushOrderToQueue(context.Background(), producer, &broker.Message{Topic: "orders", Key: []byte("b563feb7b2b84b6test")})
 
 	// Assert
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
-	}
-	if _, ok := producer.(sarama.SyncProducer); !ok {
-		t.Fatal("Expected a SyncProducer instance")
-	}
+	require.NoError(t, err)
+	messages := producer.Messages("orders")
+	require.Len(t, messages, 1)
+	assert.Equal(t, "b563feb7b2b84b6test", string(messages[0].Key))
+	assert.Empty(t, messages[0].Value)
 }
 
-// Handle empty broker list gracefully
-func TestHandleEmptyBrokerListGracefully(t *testing.T) {
+// Appends an order as a line of the topic file, keeping the CloudEvents headers
+func TestPushOrderToQueueWithFileBroker(t *testing.T) {
 	// Arrange
-	brokers := []string{}
+	dir := t.TempDir()
+	producer, err := broker.NewFile(dir, "orders-service", time.Second)
+	require.NoError(t, err)
+	event, err := envelope.New(envelope.TypeOrderCreated, "1", map[string]string{"order_uid": "1"})
+	require.NoError(t, err)
 
 	// Act
-	producer, err := ConnectProducer(brokers)
+	err = PushOrderToQueue(context.Background(), producer, event.Binary("orders"))
 
 	// Assert
-	if err == nil {
-		t.Fatal("Expected an error, got none")
-	}
-	if producer != nil {
-		t.Fatal("Expected nil producer, got a valid instance")
-	}
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "orders.ndjson"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"value":{"order_uid":"1"}`)
+	assert.Contains(t, lines[0], `{"key":"ce_type","value":"order.created"}`)
 }
 
-// Handle invalid broker addresses
-func TestHandleInvalidBrokerAddresses(t *testing.T) {
+// Returns the broker error when the message can't be published
+func TestPushOrderToQueueReturnsPublishError(t *testing.T) {
 	// Arrange
-	brokers := []string{"invalid-broker"}
+	producer := broker.NewMemory()
+	require.NoError(t, producer.Close())
 
 	// Act
-	producer, err := ConnectProducer(brokers)
+	err := PushOrderToQueue(context.Background(), producer, &broker.Message{Topic: "orders", Value: []byte(`{}`)})
 
 	// Assert
-	if err == nil {
-		t.Fatal("Expected an error, got none")
-	}
-	if producer != nil {
-		t.Fatal("Expected nil producer, got a valid instance")
-	}
+	assert.ErrorIs(t, err, broker.ErrClosed)
 }
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// Role уровень доступа; каждая следующая роль включает права предыдущих
+type Role string
+
+const (
+	RoleReader   Role = "reader"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleLevels = map[Role]int{RoleReader: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// Valid сообщает, известна ли роль
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Allows сообщает, достаточно ли роли r для доступа, требующего required
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleLevels[r] >= roleLevels[required]
+}
+
+// Способы аутентификации
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// APIKeyHeader заголовок со статическим ключом API
+const APIKeyHeader = "X-API-Key"
+
+// Principal аутентифицированный клиент
+type Principal struct {
+	Subject string
+	Role    Role
+	Method  string
+}
+
+type principalKey struct{}
+
+// FromContext возвращает клиента, аутентифицированного для запроса
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+var (
+	errNoCredentials = errors.New("no credentials")
+	errUnknownAPIKey = errors.New("unknown api key")
+)
+
+// Authenticator проверяет ключи API и JWT и пишет в журнал аудита отказы в доступе
+type Authenticator struct {
+	apiKeys   map[[sha256.Size]byte]Principal
+	jwtSecret []byte
+	jwtIssuer string
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+// New создаёт Authenticator из настроек. Ключи API задаются в формате subject:role:key.
+func New(cfg config.AuthConfig, logger *zap.Logger) (*Authenticator, error) {
+	a := &Authenticator{
+		apiKeys:   make(map[[sha256.Size]byte]Principal, len(cfg.APIKeys)),
+		jwtSecret: []byte(cfg.JWTSecret),
+		jwtIssuer: cfg.JWTIssuer,
+		logger:    logger,
+		now:       time.Now,
+	}
+	for i, entry := range cfg.APIKeys {
+		subject, role, key, err := config.ParseAPIKey(entry)
+		if err != nil {
+			return nil, fmt.Errorf("api key #%d: %w", i+1, err)
+		}
+		// Храним хеши, а не сами ключи: поиск по хешу не раскрывает ключ через время сравнения
+		a.apiKeys[sha256.Sum256([]byte(key))] = Principal{Subject: subject, Role: Role(role), Method: MethodAPIKey}
+	}
+	return a, nil
+}
+
+// Authenticate определяет клиента по заголовку X-API-Key или Authorization: Bearer
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		p, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return Principal{Method: MethodAPIKey}, errUnknownAPIKey
+		}
+		return p, nil
+	}
+
+	authorization := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, errNoCredentials
+	}
+	if len(a.jwtSecret) == 0 {
+		return Principal{Method: MethodJWT}, errors.New("bearer tokens are not accepted")
+	}
+
+	claims, err := Verify(a.jwtSecret, a.jwtIssuer, token, a.now())
+	if err != nil {
+		return Principal{Method: MethodJWT}, err
+	}
+	if !claims.Role.Valid() {
+		return Principal{Subject: claims.Subject, Method: MethodJWT}, fmt.Errorf("unknown role %q", claims.Role)
+	}
+	return Principal{Subject: claims.Subject, Role: claims.Role, Method: MethodJWT}, nil
+}
+
+// Require пропускает запрос только клиентам с ролью не ниже required.
+// Без учётных данных или с неверными отвечает 401, при недостаточной роли — 403.
+func (a *Authenticator) Require(required Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			a.audit(r, principal, required, "unauthenticated", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		if !principal.Role.Allows(required) {
+			a.audit(r, principal, required, "forbidden", nil)
+			writeError(w, http.StatusForbidden, fmt.Sprintf("role %s is required", required))
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	}
+}
+
+// audit пишет структурированное событие об отказе в доступе
+func (a *Authenticator) audit(r *http.Request, principal Principal, required Role, outcome string, err error) {
+	route := r.URL.Path
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			route = template
+		}
+	}
+
+	fields := []zap.Field{
+		zap.String("event", "auth_failure"),
+		zap.String("outcome", outcome),
+		zap.String("method", r.Method),
+		zap.String("route", route),
+		zap.String("path", r.URL.Path),
+		zap.String("remote_addr", remoteIP(r)),
+		zap.String("user_agent", r.UserAgent()),
+		zap.String("required_role", string(required)),
+	}
+	if principal.Method != "" {
+		fields = append(fields, zap.String("auth_method", principal.Method))
+	}
+	if principal.Subject != "" {
+		fields = append(fields, zap.String("subject", principal.Subject), zap.String("role", string(principal.Role)))
+	}
+	if err != nil {
+		fields = append(fields, zap.String("reason", err.Error()))
+	}
+	a.logger.Warn("Access denied", fields...)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
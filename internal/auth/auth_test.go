@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.WarnLevel)
+	a, err := New(config.AuthConfig{
+		Enabled:   true,
+		APIKeys:   []string{"dashboard:reader:reader-key", "ops:admin:admin-key"},
+		JWTSecret: string(testSecret),
+		JWTIssuer: "orders-service",
+	}, zap.New(core))
+	require.NoError(t, err)
+	return a, logs
+}
+
+func serve(a *Authenticator, required Role, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	a.Require(required, func(w http.ResponseWriter, r *http.Request) {
+		p, _ := FromContext(r.Context())
+		w.Write([]byte(p.Subject))
+	})(rec, req)
+	return rec
+}
+
+// API keys authenticate and the role is enforced per route
+func TestRequireWithAPIKey(t *testing.T) {
+	a, logs := newTestAuthenticator(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/order/1", nil)
+	req.Header.Set(APIKeyHeader, "reader-key")
+	rec := serve(a, RoleReader, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "dashboard", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodDelete, "/delorders", nil)
+	req.Header.Set(APIKeyHeader, "reader-key")
+	rec = serve(a, RoleAdmin, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/delorders", nil)
+	req.Header.Set(APIKeyHeader, "admin-key")
+	assert.Equal(t, http.StatusOK, serve(a, RoleAdmin, req).Code)
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0].ContextMap()
+	assert.Equal(t, "auth_failure", entry["event"])
+	assert.Equal(t, "forbidden", entry["outcome"])
+	assert.Equal(t, "dashboard", entry["subject"])
+	assert.Equal(t, "admin", entry["required_role"])
+}
+
+// Missing or unknown credentials are rejected with 401 and audited
+func TestRequireRejectsMissingCredentials(t *testing.T) {
+	a, logs := newTestAuthenticator(t)
+
+	rec := serve(a, RoleReader, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(APIKeyHeader, "guessed-key")
+	assert.Equal(t, http.StatusUnauthorized, serve(a, RoleReader, req).Code)
+
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, "unknown api key", logs.All()[1].ContextMap()["reason"])
+	assert.NotContains(t, logs.All()[1].ContextMap(), "guessed-key")
+}
+
+// Bearer tokens are accepted only with a valid signature, issuer and lifetime
+func TestRequireWithJWT(t *testing.T) {
+	a, _ := newTestAuthenticator(t)
+	now := time.Now()
+
+	sign := func(secret []byte, claims Claims) string {
+		token, err := Sign(secret, claims)
+		require.NoError(t, err)
+		return token
+	}
+	valid := Claims{Subject: "alice", Role: RoleOperator, Issuer: "orders-service", ExpiresAt: now.Add(time.Hour).Unix()}
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Hour).Unix()
+	otherIssuer := valid
+	otherIssuer.Issuer = "someone-else"
+
+	for name, tc := range map[string]struct {
+		token string
+		code  int
+	}{
+		"valid":        {sign(testSecret, valid), http.StatusOK},
+		"expired":      {sign(testSecret, expired), http.StatusUnauthorized},
+		"wrong secret": {sign([]byte("another-secret-another-secret-00"), valid), http.StatusUnauthorized},
+		"wrong issuer": {sign(testSecret, otherIssuer), http.StatusUnauthorized},
+		"alg none":     {"eyJhbGciOiJub25lIn0.eyJzdWIiOiJhbGljZSJ9.", http.StatusUnauthorized},
+		"not a jwt":    {"garbage", http.StatusUnauthorized},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/order/1", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			assert.Equal(t, tc.code, serve(a, RoleOperator, req).Code)
+		})
+	}
+}
+
+// Higher roles include the permissions of lower ones
+func TestRoleAllows(t *testing.T) {
+	assert.True(t, RoleAdmin.Allows(RoleOperator))
+	assert.True(t, RoleOperator.Allows(RoleReader))
+	assert.False(t, RoleReader.Allows(RoleOperator))
+	assert.False(t, Role("root").Allows(RoleReader))
+}
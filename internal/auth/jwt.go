@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// leeway допустимое расхождение часов при проверке exp и nbf
+const leeway = 30 * time.Second
+
+var (
+	ErrMalformedToken = errors.New("malformed token")
+	ErrBadSignature   = errors.New("invalid token signature")
+	ErrTokenExpired   = errors.New("token expired")
+	ErrTokenNotActive = errors.New("token not active yet")
+	ErrWrongIssuer    = errors.New("unexpected token issuer")
+)
+
+// Claims поля JWT, которые использует сервис
+type Claims struct {
+	Subject   string `json:"sub"`
+	Role      Role   `json:"role"`
+	Issuer    string `json:"iss,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// Sign выпускает токен HS256 с claims, подписанный secret
+func Sign(secret []byte, claims Claims) (string, error) {
+	head, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := encoding.EncodeToString(head) + "." + encoding.EncodeToString(body)
+	return unsigned + "." + encoding.EncodeToString(signature(secret, unsigned)), nil
+}
+
+// Verify проверяет подпись HS256 и сроки действия токена и возвращает его claims.
+// Если issuer не пустой, он должен совпадать с iss токена.
+func Verify(secret []byte, issuer, token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var head header
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return nil, err
+	}
+	// Принимаем только HS256, чтобы токен с alg=none или другим алгоритмом не прошёл проверку
+	if head.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrMalformedToken, head.Alg)
+	}
+
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	if !hmac.Equal(sig, signature(secret, parts[0]+"."+parts[1])) {
+		return nil, ErrBadSignature
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrTokenNotActive
+	}
+	if issuer != "" && claims.Issuer != issuer {
+		return nil, ErrWrongIssuer
+	}
+	return &claims, nil
+}
+
+func signature(secret []byte, unsigned string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := encoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformedToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrMalformedToken
+	}
+	return nil
+}
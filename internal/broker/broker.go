@@ -0,0 +1,151 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+)
+
+// Типы брокеров, из которых выбирает настройка broker.type
+const (
+	TypeKafka  = "kafka"
+	TypeMemory = "memory"
+	TypeFile   = "file"
+)
+
+// ErrClosed возвращается при отправке сообщения в закрытый брокер
+var ErrClosed = errors.New("broker is closed")
+
+// Header заголовок сообщения
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Message сообщение брокера. Topic, Key, Value и Headers задаёт отправитель,
+// Partition, Offset и Timestamp заполняет брокер. Пустой Value — tombstone.
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	Timestamp time.Time
+}
+
+// Header возвращает значение заголовка key; если заголовок повторяется, берётся последний
+func (m *Message) Header(key string) (string, bool) {
+	for i := len(m.Headers) - 1; i >= 0; i-- {
+		if m.Headers[i].Key == key {
+			return m.Headers[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// Delivery полученное сообщение. Обработчик подтверждает его Ack или возвращает Nack;
+// сообщение без Ack считается возвращённым.
+type Delivery struct {
+	*Message
+	// Lag сколько сообщений партиции ещё не прочитано после этого; -1, если неизвестно
+	Lag  int64
+	ack  func()
+	nack func()
+}
+
+// Ack подтверждает обработку: после перезапуска чтение продолжится со следующего сообщения
+func (d Delivery) Ack() {
+	if d.ack != nil {
+		d.ack()
+	}
+}
+
+// Nack возвращает сообщение: текущий сеанс чтения завершается для всех партиций,
+// и после паузы сообщение будет доставлено повторно в следующем. Сообщение, для которого
+// не вызваны ни Ack, ни Nack, тоже завершает сеанс.
+func (d Delivery) Nack() {
+	if d.nack != nil {
+		d.nack()
+	}
+}
+
+// Handler обрабатывает сообщения подписки. Сообщения одной партиции передаются по очереди,
+// сообщения разных партиций могут обрабатываться одновременно.
+type Handler interface {
+	// Started вызывается, когда начинается сеанс чтения, в том числе после ребалансировки
+	Started()
+	// Stopped вызывается в конце сеанса, когда подтверждённые сообщения уже закоммичены
+	Stopped()
+	// Handle обрабатывает сообщение; ctx отменяется, когда сеанс завершается
+	Handle(ctx context.Context, d Delivery)
+	// Error сообщает об ошибке брокера, после которой чтение продолжается
+	Error(err error)
+}
+
+// Publisher отправляет сообщения
+type Publisher interface {
+	// Publish отправляет сообщение в msg.Topic и заполняет его Partition, Offset и Timestamp
+	Publish(ctx context.Context, msg *Message) error
+}
+
+// Broker брокер сообщений
+type Broker interface {
+	Publisher
+	// Subscribe читает topic до отмены ctx или закрытия брокера и передаёт сообщения в handler.
+	// Чтение продолжается с последнего подтверждённого сообщения группы из настроек.
+	Subscribe(ctx context.Context, topic string, handler Handler) error
+	// Close освобождает соединения и файлы брокера
+	Close() error
+}
+
+// Open создаёт брокер, выбранный в cfg.Broker.Type
+func Open(cfg *config.Config) (Broker, error) {
+	switch cfg.Broker.Type {
+	case TypeKafka:
+		return NewKafka(cfg.Kafka)
+	case TypeMemory:
+		return NewMemory(), nil
+	case TypeFile:
+		return NewFile(cfg.Broker.Dir, cfg.Kafka.GroupID, cfg.Broker.PollInterval)
+	default:
+		return nil, fmt.Errorf("unknown broker type %q", cfg.Broker.Type)
+	}
+}
+
+// restartDelay пауза перед новым сеансом после Nack, чтобы не доставлять одно и то же сообщение без остановки
+const restartDelay = time.Second
+
+// runSessions повторяет сеансы чтения, пока не отменён ctx и не закрыт брокер.
+// Сеанс session заканчивается после Nack, отмены ctx или закрытия брокера.
+func runSessions(ctx context.Context, closed <-chan struct{}, handler Handler, session func(ctx context.Context) error) error {
+	for {
+		handler.Started()
+		err := session(ctx)
+		handler.Stopped()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-closed:
+			return nil
+		case <-time.After(restartDelay):
+		}
+	}
+}
+
+// cloneMessage копирует сообщение, чтобы получатель не мог изменить сохранённое брокером
+func cloneMessage(msg Message) Message {
+	msg.Key = append([]byte(nil), msg.Key...)
+	if msg.Value != nil {
+		msg.Value = append([]byte{}, msg.Value...)
+	}
+	msg.Headers = append([]Header(nil), msg.Headers...)
+	return msg
+}
@@ -0,0 +1,319 @@
+package broker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler collects delivered messages and acks them unless told to nack
+type recordingHandler struct {
+	mu       sync.Mutex
+	values   []string
+	nackNext int
+	started  int
+	received chan struct{}
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{received: make(chan struct{}, 100)}
+}
+
+func (h *recordingHandler) Started() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.started++
+}
+
+func (h *recordingHandler) Stopped() {}
+
+func (h *recordingHandler) Error(error) {}
+
+func (h *recordingHandler) Handle(ctx context.Context, d Delivery) {
+	h.mu.Lock()
+	h.values = append(h.values, string(d.Value))
+	nack := h.nackNext > 0
+	if nack {
+		h.nackNext--
+	}
+	h.mu.Unlock()
+
+	if nack {
+		d.Nack()
+	} else {
+		d.Ack()
+	}
+	h.received <- struct{}{}
+}
+
+func (h *recordingHandler) Values() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.values...)
+}
+
+// subscribe runs a subscription in the background and returns a function that stops it
+func subscribe(t *testing.T, b Broker, topic string, handler Handler) func() {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Subscribe(ctx, topic, handler) }()
+	return func() {
+		cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("subscription did not stop")
+		}
+	}
+}
+
+func waitReceived(t *testing.T, h *recordingHandler, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-h.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d of %d messages", i, n)
+		}
+	}
+}
+
+func publish(t *testing.T, b Broker, topic, value string, headers ...Header) *Message {
+	t.Helper()
+	msg := &Message{Topic: topic, Key: []byte("key-" + value), Value: []byte(value), Headers: headers}
+	require.NoError(t, b.Publish(context.Background(), msg))
+	return msg
+}
+
+// backends returns every broker that runs without external services
+func backends(t *testing.T) map[string]func() Broker {
+	dir := t.TempDir()
+	return map[string]func() Broker{
+		"memory": func() Broker { return NewMemory() },
+		"file": func() Broker {
+			b, err := NewFile(dir, "test-group", 10*time.Millisecond)
+			require.NoError(t, err)
+			return b
+		},
+	}
+}
+
+// Messages published before and after subscribing are delivered in order with key and headers
+func TestBrokerDeliversMessagesInOrder(t *testing.T) {
+	for name, open := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			b := open()
+			defer b.Close()
+			first := publish(t, b, "orders", `{"n":1}`, Header{Key: "dlq-attempts", Value: "2"})
+
+			var delivered *Message
+			handler := &keyHandler{recordingHandler: newRecordingHandler(), onMessage: func(msg *Message) {
+				if delivered == nil {
+					delivered = msg
+				}
+			}}
+
+			// Act
+			stop := subscribe(t, b, "orders", handler)
+			second := publish(t, b, "orders", "not json")
+			waitReceived(t, handler.recordingHandler, 2)
+			stop()
+
+			// Assert
+			assert.Equal(t, []string{`{"n":1}`, "not json"}, handler.Values())
+			assert.Less(t, first.Offset, second.Offset)
+			require.NotNil(t, delivered)
+			assert.Equal(t, "key-{\"n\":1}", string(delivered.Key))
+			assert.Equal(t, first.Offset, delivered.Offset)
+			attempts, ok := delivered.Header("dlq-attempts")
+			assert.True(t, ok)
+			assert.Equal(t, "2", attempts)
+		})
+	}
+}
+
+// Headers are delivered in publish order, and a repeated key keeps every value
+func TestBrokerKeepsRepeatedHeaders(t *testing.T) {
+	for name, open := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			b := open()
+			defer b.Close()
+			headers := []Header{{Key: "trace", Value: "1"}, {Key: "dlq-attempts", Value: "1"}, {Key: "trace", Value: "2"}}
+			publish(t, b, "headers", "{}", headers...)
+
+			var delivered *Message
+			handler := &keyHandler{recordingHandler: newRecordingHandler(), onMessage: func(msg *Message) { delivered = msg }}
+
+			// Act
+			stop := subscribe(t, b, "headers", handler)
+			waitReceived(t, handler.recordingHandler, 1)
+			stop()
+
+			// Assert
+			require.NotNil(t, delivered)
+			assert.Equal(t, headers, delivered.Headers)
+		})
+	}
+}
+
+// keyHandler passes each delivered message to onMessage before recording it
+type keyHandler struct {
+	*recordingHandler
+	onMessage func(msg *Message)
+}
+
+func (h *keyHandler) Handle(ctx context.Context, d Delivery) {
+	h.onMessage(d.Message)
+	h.recordingHandler.Handle(ctx, d)
+}
+
+// A new subscription continues after the last acknowledged message
+func TestBrokerResumesAfterAckedMessages(t *testing.T) {
+	for name, open := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			b := open()
+			defer b.Close()
+			publish(t, b, "resume", "1")
+			publish(t, b, "resume", "2")
+			first := newRecordingHandler()
+			stop := subscribe(t, b, "resume", first)
+			waitReceived(t, first, 2)
+			stop()
+
+			// Act
+			publish(t, b, "resume", "3")
+			second := newRecordingHandler()
+			stop = subscribe(t, b, "resume", second)
+			waitReceived(t, second, 1)
+			stop()
+
+			// Assert
+			assert.Equal(t, []string{"1", "2"}, first.Values())
+			assert.Equal(t, []string{"3"}, second.Values())
+		})
+	}
+}
+
+// A nacked message is delivered again in the next session
+func TestBrokerRedeliversNackedMessage(t *testing.T) {
+	for name, open := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			b := open()
+			defer b.Close()
+			publish(t, b, "nack", "1")
+			publish(t, b, "nack", "2")
+			handler := newRecordingHandler()
+			handler.nackNext = 1
+
+			// Act
+			stop := subscribe(t, b, "nack", handler)
+			waitReceived(t, handler, 3)
+			stop()
+
+			// Assert
+			assert.Equal(t, []string{"1", "1", "2"}, handler.Values())
+			assert.Equal(t, 2, handler.started, "Nack should end the session")
+		})
+	}
+}
+
+// Publishing to a closed broker fails and subscriptions end
+func TestBrokerClose(t *testing.T) {
+	for name, open := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			b := open()
+			done := make(chan error, 1)
+			go func() { done <- b.Subscribe(context.Background(), "closed", newRecordingHandler()) }()
+
+			require.NoError(t, b.Close())
+
+			select {
+			case err := <-done:
+				assert.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("subscription did not stop after close")
+			}
+			assert.ErrorIs(t, b.Publish(context.Background(), &Message{Topic: "closed"}), ErrClosed)
+		})
+	}
+}
+
+// Lines appended by hand are read as bare values, and an unfinished line waits for its newline
+func TestFileReadsHandWrittenLines(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	b, err := NewFile(dir, "group", 10*time.Millisecond)
+	require.NoError(t, err)
+	defer b.Close()
+	path := filepath.Join(dir, "orders.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte("{\"order_uid\":\"1\"}\n\n{\"order_uid\":"), 0o644))
+	handler := newRecordingHandler()
+
+	// Act
+	stop := subscribe(t, b, "orders", handler)
+	waitReceived(t, handler, 1)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString("\"2\"}\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	waitReceived(t, handler, 1)
+	stop()
+
+	// Assert
+	assert.Equal(t, []string{`{"order_uid":"1"}`, `{"order_uid":"2"}`}, handler.Values())
+	offset, err := os.ReadFile(filepath.Join(dir, "orders.group.offset"))
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	committed, err := strconv.ParseInt(strings.TrimSpace(string(offset)), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), committed)
+}
+
+// Records round-trip JSON, text and empty values, keeping header order and repeated keys
+func TestFileRecordRoundTrip(t *testing.T) {
+	headers := []Header{{Key: "b", Value: "2"}, {Key: "a", Value: "1"}, {Key: "b", Value: "3"}}
+	for _, value := range [][]byte{[]byte(`{"a": [1, 2]}`), []byte("plain text"), nil} {
+		msg := &Message{Key: []byte("k"), Value: value, Headers: headers}
+
+		line, err := encodeRecord(msg)
+		require.NoError(t, err)
+		decoded := decodeRecord(line[:len(line)-1])
+
+		assert.Equal(t, "k", string(decoded.Key))
+		assert.Equal(t, headers, decoded.Headers)
+		switch {
+		case value == nil:
+			assert.Empty(t, decoded.Value)
+		case string(value) == "plain text":
+			assert.Equal(t, "plain text", string(decoded.Value))
+		default:
+			assert.JSONEq(t, string(value), string(decoded.Value))
+		}
+	}
+}
+
+// Topic names cannot point outside the broker directory
+func TestFileRejectsInvalidTopics(t *testing.T) {
+	b, err := NewFile(t.TempDir(), "group", time.Second)
+	require.NoError(t, err)
+
+	for _, topic := range []string{"", "../orders", "a/b", ".hidden"} {
+		assert.Error(t, b.Publish(context.Background(), &Message{Topic: topic}), topic)
+	}
+}
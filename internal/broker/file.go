@@ -0,0 +1,303 @@
+package broker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File брокер на каталоге с NDJSON-файлами: топик — файл <topic>.ndjson, сообщение — строка.
+// Строка с полем value — запись с ключом и заголовками, любая другая строка целиком
+// считается значением сообщения, поэтому заказы можно дописывать в файл и вручную.
+// Смещение группы хранится в <topic>.<group>.offset как позиция в байтах.
+type File struct {
+	dir          string
+	group        string
+	pollInterval time.Duration
+
+	// mu упорядочивает запись в файлы внутри процесса
+	mu        sync.Mutex
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// fileRecord строка файла топика. Значение, которое не является JSON, хранится в Text,
+// а Value тогда равно null. Заголовки хранятся списком, как в Kafka: с порядком и повторами ключей
+type fileRecord struct {
+	Key       string          `json:"key,omitempty"`
+	Headers   []fileHeader    `json:"headers,omitempty"`
+	Timestamp *time.Time      `json:"timestamp,omitempty"`
+	Value     json.RawMessage `json:"value"`
+	Text      string          `json:"text,omitempty"`
+}
+
+// fileHeader заголовок сообщения в строке файла топика
+type fileHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// NewFile создаёт брокер в каталоге dir; group определяет файл смещений
+func NewFile(dir, group string, pollInterval time.Duration) (*File, error) {
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive, got %s", pollInterval)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create broker directory: %w", err)
+	}
+	return &File{dir: dir, group: group, pollInterval: pollInterval, closed: make(chan struct{})}, nil
+}
+
+// Publish дописывает сообщение строкой в конец файла топика
+func (f *File) Publish(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-f.closed:
+		return ErrClosed
+	default:
+	}
+	path, err := f.topicPath(msg.Topic)
+	if err != nil {
+		return err
+	}
+
+	msg.Partition = 0
+	msg.Timestamp = time.Now().UTC()
+	line, err := encodeRecord(msg)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open topic %s: %w", msg.Topic, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("failed to write to topic %s: %w", msg.Topic, err)
+	}
+	end, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to get position in topic %s: %w", msg.Topic, err)
+	}
+	msg.Offset = end - int64(len(line))
+	return nil
+}
+
+// Subscribe читает файл топика с сохранённого смещения и ждёт новых строк,
+// проверяя файл каждые pollInterval. Незаконченная последняя строка ждёт, пока её допишут.
+func (f *File) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	path, err := f.topicPath(topic)
+	if err != nil {
+		return err
+	}
+	return runSessions(ctx, f.closed, handler, func(ctx context.Context) error {
+		return f.session(ctx, topic, path, handler)
+	})
+}
+
+// session доставляет строки по одной, пока обработчик их подтверждает
+func (f *File) session(ctx context.Context, topic, path string, handler Handler) error {
+	offset, err := f.readOffset(topic)
+	if err != nil {
+		return err
+	}
+
+	var (
+		file   *os.File
+		reader *bufio.Reader
+	)
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for {
+		if file == nil {
+			file, err = os.Open(path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to open topic %s: %w", topic, err)
+			}
+			if file != nil {
+				if _, err := file.Seek(offset, io.SeekStart); err != nil {
+					return fmt.Errorf("failed to seek topic %s: %w", topic, err)
+				}
+				reader = bufio.NewReader(file)
+			}
+		}
+
+		var line []byte
+		if reader != nil {
+			line, err = reader.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("failed to read topic %s: %w", topic, err)
+			}
+		}
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			// Строку ещё не дописали: вернёмся к её началу и подождём
+			if file != nil {
+				if _, err := file.Seek(offset, io.SeekStart); err != nil {
+					return fmt.Errorf("failed to seek topic %s: %w", topic, err)
+				}
+				reader.Reset(file)
+			}
+			select {
+			case <-time.After(f.pollInterval):
+				continue
+			case <-ctx.Done():
+				return nil
+			case <-f.closed:
+				return nil
+			}
+		}
+
+		lineOffset := offset
+		offset += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		msg := decodeRecord(line)
+		msg.Topic = topic
+		msg.Offset = lineOffset
+		next := offset
+		acked := false
+		var ackErr error
+		handler.Handle(ctx, Delivery{
+			Message: &msg,
+			Lag:     -1,
+			ack: func() {
+				ackErr = f.writeOffset(topic, next)
+				acked = true
+			},
+		})
+		if ackErr != nil {
+			handler.Error(ackErr)
+		}
+		if !acked {
+			return nil
+		}
+	}
+}
+
+// topicPath возвращает путь к файлу топика; имя топика не может указывать за пределы каталога
+func (f *File) topicPath(topic string) (string, error) {
+	if topic == "" || topic != filepath.Base(topic) || strings.HasPrefix(topic, ".") {
+		return "", fmt.Errorf("invalid topic name %q", topic)
+	}
+	return filepath.Join(f.dir, topic+".ndjson"), nil
+}
+
+func (f *File) offsetPath(topic string) string {
+	return filepath.Join(f.dir, topic+"."+f.group+".offset")
+}
+
+// readOffset читает смещение группы; без файла смещений чтение начинается с начала топика
+func (f *File) readOffset(topic string) (int64, error) {
+	data, err := os.ReadFile(f.offsetPath(topic))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read offset of topic %s: %w", topic, err)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset of topic %s: %q", topic, data)
+	}
+	return offset, nil
+}
+
+// writeOffset сохраняет смещение группы через временный файл, чтобы не оставить его недописанным
+func (f *File) writeOffset(topic string, offset int64) error {
+	path := f.offsetPath(topic)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to save offset of topic %s: %w", topic, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save offset of topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Close останавливает подписки; новые сообщения больше не принимаются
+func (f *File) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return nil
+}
+
+// encodeRecord переводит сообщение в строку файла топика
+func encodeRecord(msg *Message) ([]byte, error) {
+	record := fileRecord{Key: string(msg.Key), Timestamp: &msg.Timestamp}
+	for _, header := range msg.Headers {
+		record.Headers = append(record.Headers, fileHeader{Key: header.Key, Value: header.Value})
+	}
+
+	var compact bytes.Buffer
+	switch {
+	case len(msg.Value) == 0:
+	case json.Compact(&compact, msg.Value) == nil:
+		record.Value = compact.Bytes()
+	default:
+		record.Text = string(msg.Value)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return append(line, '\n'), nil
+}
+
+// decodeRecord разбирает строку файла топика; строка без поля value целиком становится значением
+func decodeRecord(line []byte) Message {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(line, &fields) != nil {
+		return Message{Value: line}
+	}
+	if _, ok := fields["value"]; !ok {
+		return Message{Value: line}
+	}
+
+	var record fileRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return Message{Value: line}
+	}
+
+	msg := Message{}
+	if record.Key != "" {
+		msg.Key = []byte(record.Key)
+	}
+	if record.Timestamp != nil {
+		msg.Timestamp = *record.Timestamp
+	}
+	switch {
+	case record.Text != "":
+		msg.Value = []byte(record.Text)
+	case len(record.Value) > 0 && string(record.Value) != "null":
+		msg.Value = record.Value
+	}
+	for _, header := range record.Headers {
+		msg.Headers = append(msg.Headers, Header{Key: header.Key, Value: header.Value})
+	}
+	return msg
+}
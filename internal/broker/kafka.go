@@ -0,0 +1,222 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+)
+
+// Kafka брокер поверх Kafka: сообщения отправляются синхронным продюсером,
+// а подписка читает топик как участник consumer group из настроек.
+type Kafka struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	groupID  string
+}
+
+// NewKafka подключается к брокерам из cfg
+func NewKafka(cfg config.KafkaConfig) (*Kafka, error) {
+	saramaCfg, err := newSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sarama.NewClient(cfg.Brokers, saramaCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kafka: %w", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+	return &Kafka{client: client, producer: producer, groupID: cfg.GroupID}, nil
+}
+
+// newSaramaConfig переводит config.KafkaConfig в настройки sarama для продюсера и consumer group.
+func newSaramaConfig(cfg config.KafkaConfig) (*sarama.Config, error) {
+	saramaCfg, err := cfg.Sarama()
+	if err != nil {
+		return nil, err
+	}
+	saramaCfg.Producer.Return.Successes = true
+	saramaCfg.Producer.RequiredAcks = sarama.WaitForAll
+	saramaCfg.Consumer.Return.Errors = true
+	if cfg.SessionTimeout > 0 {
+		saramaCfg.Consumer.Group.Session.Timeout = cfg.SessionTimeout
+	}
+	if cfg.HeartbeatInterval > 0 {
+		saramaCfg.Consumer.Group.Heartbeat.Interval = cfg.HeartbeatInterval
+	}
+
+	switch cfg.InitialOffset {
+	case "", "oldest":
+		saramaCfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case "newest":
+		saramaCfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		return nil, fmt.Errorf("unknown initial offset %q", cfg.InitialOffset)
+	}
+
+	switch cfg.RebalanceStrategy {
+	case "", sarama.RangeBalanceStrategyName:
+		saramaCfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	case sarama.RoundRobinBalanceStrategyName:
+		saramaCfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	case sarama.StickyBalanceStrategyName:
+		saramaCfg.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	default:
+		return nil, fmt.Errorf("unknown rebalance strategy %q", cfg.RebalanceStrategy)
+	}
+
+	return saramaCfg, nil
+}
+
+// Publish отправляет сообщение и дожидается подтверждения всех реплик
+func (k *Kafka) Publish(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	producerMsg := &sarama.ProducerMessage{Topic: msg.Topic}
+	if len(msg.Key) > 0 {
+		producerMsg.Key = sarama.ByteEncoder(msg.Key)
+	}
+	// nil-значение отправляется как tombstone
+	if len(msg.Value) > 0 {
+		producerMsg.Value = sarama.ByteEncoder(msg.Value)
+	}
+	for _, header := range msg.Headers {
+		producerMsg.Headers = append(producerMsg.Headers, sarama.RecordHeader{Key: []byte(header.Key), Value: []byte(header.Value)})
+	}
+
+	partition, offset, err := k.producer.SendMessage(producerMsg)
+	if err != nil {
+		return err
+	}
+	msg.Partition = partition
+	msg.Offset = offset
+	msg.Timestamp = producerMsg.Timestamp
+	return nil
+}
+
+// Subscribe читает все партиции топика, назначенные участнику группы.
+// Consume возвращается при каждой ребалансировке и после Nack, поэтому вызывается в цикле, пока ctx не отменён.
+func (k *Kafka) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	group, err := sarama.NewConsumerGroupFromClient(k.groupID, k.client)
+	if err != nil {
+		return fmt.Errorf("failed to join consumer group: %w", err)
+	}
+	defer group.Close()
+
+	go func() {
+		for err := range group.Errors() {
+			handler.Error(err)
+		}
+	}()
+
+	for {
+		// Сессию завершает и Nack: следующая начнётся с последнего закоммиченного смещения
+		sessionCtx, endSession := context.WithCancel(ctx)
+		err := group.Consume(sessionCtx, []string{topic}, kafkaGroupHandler{handler: handler, endSession: endSession})
+		nacked := sessionCtx.Err() != nil
+		endSession()
+		if err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) || errors.Is(err, sarama.ErrClosedClient) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if nacked {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(restartDelay):
+			}
+		}
+	}
+}
+
+// Close закрывает продюсер и соединение с брокерами
+func (k *Kafka) Close() error {
+	return errors.Join(k.producer.Close(), k.client.Close())
+}
+
+// kafkaGroupHandler реализует sarama.ConsumerGroupHandler поверх Handler.
+// Смещение сообщения помечается к коммиту только после Ack.
+type kafkaGroupHandler struct {
+	handler Handler
+	// endSession завершает текущую сессию группы
+	endSession context.CancelFunc
+}
+
+// Setup вызывается в начале новой сессии, до ConsumeClaim.
+func (h kafkaGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+	h.handler.Started()
+	return nil
+}
+
+// Cleanup вызывается в конце сессии, после завершения всех ConsumeClaim.
+// Помеченные смещения коммитятся сразу, не дожидаясь автокоммита,
+// чтобы при остановке и ребалансировке не перечитывать уже обработанные сообщения.
+func (h kafkaGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	h.handler.Stopped()
+	return nil
+}
+
+// ConsumeClaim передаёт сообщения одной партиции обработчику по очереди.
+// Неподтверждённое сообщение завершает всю сессию: сообщение будет прочитано повторно в следующей.
+func (h kafkaGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			acked := false
+			h.handler.Handle(session.Context(), Delivery{
+				Message: fromSarama(msg),
+				Lag:     claim.HighWaterMarkOffset() - msg.Offset - 1,
+				ack: func() {
+					session.MarkMessage(msg, "")
+					acked = true
+				},
+				nack: h.endSession,
+			})
+			if !acked {
+				h.endSession()
+				return nil
+			}
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// fromSarama переводит сообщение sarama в Message
+func fromSarama(msg *sarama.ConsumerMessage) *Message {
+	headers := make([]Header, 0, len(msg.Headers))
+	for _, header := range msg.Headers {
+		if header != nil {
+			headers = append(headers, Header{Key: string(header.Key), Value: string(header.Value)})
+		}
+	}
+	return &Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   headers,
+		Timestamp: msg.Timestamp,
+	}
+}
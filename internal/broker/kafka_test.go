@@ -0,0 +1,197 @@
+package broker
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func testKafkaConfig() config.KafkaConfig {
+	return config.KafkaConfig{
+		Brokers:           []string{"localhost:9092"},
+		Topic:             "orders",
+		GroupID:           "orders-service-test",
+		InitialOffset:     "oldest",
+		RebalanceStrategy: "range",
+		DLQTopic:          "orders.dlq",
+		MaxAttempts:       3,
+	}
+}
+
+// testBrokersEnv names the variable with comma-separated addresses of a Kafka cluster for tests
+const testBrokersEnv = "TEST_KAFKA_BROKERS"
+
+// Successfully connects to a Kafka broker with valid broker addresses
+func TestNewKafkaWithValidBrokers(t *testing.T) {
+	// Arrange
+	brokers := os.Getenv(testBrokersEnv)
+	if brokers == "" {
+		t.Skipf("%s is not set", testBrokersEnv)
+	}
+	cfg := testKafkaConfig()
+	cfg.Brokers = strings.Split(brokers, ",")
+
+	// Act
+	kafka, err := NewKafka(cfg)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if kafka == nil {
+		t.Fatal("Expected a non-nil broker")
+	}
+	_ = kafka.Close()
+}
+
+// Handles empty broker list gracefully
+func TestNewKafkaWithEmptyBrokerList(t *testing.T) {
+	// Arrange
+	cfg := testKafkaConfig()
+	cfg.Brokers = []string{}
+
+	// Act
+	kafka, err := NewKafka(cfg)
+
+	// Assert
+	if err == nil {
+		t.Fatal("Expected an error for empty broker list")
+	}
+	if kafka != nil {
+		t.Fatal("Expected a nil broker for empty broker list")
+	}
+}
+
+// Returns an error if broker addresses are invalid
+func TestNewKafkaWithInvalidBrokers(t *testing.T) {
+	// Arrange
+	cfg := testKafkaConfig()
+	cfg.Brokers = []string{"invalid-broker-address"}
+
+	// Act
+	kafka, err := NewKafka(cfg)
+
+	// Assert
+	if err == nil {
+		t.Fatal("Expected an error for invalid broker addresses")
+	}
+	if kafka != nil {
+		t.Fatal("Expected a nil broker for invalid broker addresses")
+	}
+}
+
+// Rejects unknown initial offset and rebalance strategy values
+func TestNewSaramaConfigRejectsUnknownValues(t *testing.T) {
+	cfg := testKafkaConfig()
+	cfg.InitialOffset = "latest"
+	_, err := newSaramaConfig(cfg)
+	assert.Error(t, err)
+
+	cfg = testKafkaConfig()
+	cfg.RebalanceStrategy = "random"
+	_, err = newSaramaConfig(cfg)
+	assert.Error(t, err)
+}
+
+// Maps config values onto sarama producer and consumer group settings
+func TestNewSaramaConfigMapsSettings(t *testing.T) {
+	cfg := testKafkaConfig()
+	cfg.InitialOffset = "newest"
+	cfg.RebalanceStrategy = "sticky"
+	cfg.ClientID = "orders-test"
+	cfg.Version = "2.8.0"
+	cfg.SessionTimeout = 45 * time.Second
+
+	saramaCfg, err := newSaramaConfig(cfg)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "orders-test", saramaCfg.ClientID)
+	assert.Equal(t, sarama.V2_8_0_0, saramaCfg.Version)
+	assert.Equal(t, 45*time.Second, saramaCfg.Consumer.Group.Session.Timeout)
+	assert.Equal(t, sarama.OffsetNewest, saramaCfg.Consumer.Offsets.Initial)
+	assert.Equal(t, sarama.StickyBalanceStrategyName, saramaCfg.Consumer.Group.Rebalance.GroupStrategies[0].Name())
+	assert.True(t, saramaCfg.Producer.Return.Successes)
+	assert.Equal(t, sarama.WaitForAll, saramaCfg.Producer.RequiredAcks)
+}
+
+// Converts consumed sarama messages, skipping nil headers
+func TestFromSarama(t *testing.T) {
+	msg := fromSarama(&sarama.ConsumerMessage{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    7,
+		Key:       []byte("123"),
+		Value:     []byte("{}"),
+		Headers:   []*sarama.RecordHeader{nil, {Key: []byte("dlq-attempts"), Value: []byte("2")}},
+	})
+
+	assert.Equal(t, int32(2), msg.Partition)
+	assert.Equal(t, int64(7), msg.Offset)
+	attempts, ok := msg.Header("dlq-attempts")
+	assert.True(t, ok)
+	assert.Equal(t, "2", attempts)
+}
+
+// fakeSession is a consumer group session that records marked messages
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+// fakeClaim is a partition claim with a fixed set of messages
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 3 }
+
+// nackingHandler acks every message except the one at nackOffset
+type nackingHandler struct {
+	recordingHandler
+	nackOffset int64
+}
+
+func (h *nackingHandler) Handle(ctx context.Context, d Delivery) {
+	if d.Offset == h.nackOffset {
+		d.Nack()
+		return
+	}
+	d.Ack()
+}
+
+// A Nack ends the whole session so the message is read again in the next one
+func TestKafkaNackEndsSession(t *testing.T) {
+	// Arrange
+	ctx, endSession := context.WithCancel(context.Background())
+	defer endSession()
+	session := &fakeSession{ctx: ctx}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	for offset := int64(0); offset < 3; offset++ {
+		claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: offset}
+	}
+	handler := kafkaGroupHandler{handler: &nackingHandler{nackOffset: 1}, endSession: endSession}
+
+	// Act
+	err := handler.ConsumeClaim(session, claim)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0}, session.marked)
+	assert.Error(t, ctx.Err(), "session must end after a Nack")
+	assert.Len(t, claim.messages, 1, "messages after the nacked one must not be handled")
+}
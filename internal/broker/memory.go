@@ -0,0 +1,164 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Memory брокер внутри процесса для тестов и демонстрации в одном бинарнике.
+// Каждый топик — одна партиция; смещение группы хранится в памяти,
+// поэтому повторная подписка продолжает чтение с последнего подтверждённого сообщения.
+type Memory struct {
+	mu        sync.Mutex
+	topics    map[string]*memoryTopic
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// memoryTopic сообщения топика и смещение группы
+type memoryTopic struct {
+	messages   []Message
+	committed  int64
+	subscribed bool
+	// published закрывается и заменяется при каждой публикации, чтобы разбудить читателя
+	published chan struct{}
+}
+
+// NewMemory создаёт пустой брокер в памяти
+func NewMemory() *Memory {
+	return &Memory{topics: make(map[string]*memoryTopic), closed: make(chan struct{})}
+}
+
+// topic возвращает топик, создавая его при первом обращении; вызывается под m.mu
+func (m *Memory) topic(name string) *memoryTopic {
+	t, ok := m.topics[name]
+	if !ok {
+		t = &memoryTopic{published: make(chan struct{})}
+		m.topics[name] = t
+	}
+	return t
+}
+
+// Publish добавляет сообщение в конец топика
+func (m *Memory) Publish(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-m.closed:
+		return ErrClosed
+	default:
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.topic(msg.Topic)
+	msg.Partition = 0
+	msg.Offset = int64(len(t.messages))
+	msg.Timestamp = time.Now().UTC()
+	t.messages = append(t.messages, cloneMessage(*msg))
+
+	close(t.published)
+	t.published = make(chan struct{})
+	return nil
+}
+
+// Subscribe читает топик с последнего подтверждённого сообщения.
+// У топика может быть только один подписчик одновременно.
+func (m *Memory) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	m.mu.Lock()
+	t := m.topic(topic)
+	if t.subscribed {
+		m.mu.Unlock()
+		return fmt.Errorf("topic %s already has a subscriber", topic)
+	}
+	t.subscribed = true
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		t.subscribed = false
+		m.mu.Unlock()
+	}()
+
+	return runSessions(ctx, m.closed, handler, func(ctx context.Context) error {
+		m.session(ctx, t, handler)
+		return nil
+	})
+}
+
+// session доставляет сообщения по одному, пока обработчик их подтверждает
+func (m *Memory) session(ctx context.Context, t *memoryTopic, handler Handler) {
+	m.mu.Lock()
+	next := t.committed
+	m.mu.Unlock()
+
+	for {
+		m.mu.Lock()
+		if next < int64(len(t.messages)) {
+			msg := cloneMessage(t.messages[next])
+			lag := int64(len(t.messages)) - next - 1
+			m.mu.Unlock()
+
+			acked := false
+			handler.Handle(ctx, Delivery{
+				Message: &msg,
+				Lag:     lag,
+				ack: func() {
+					m.mu.Lock()
+					t.committed = max(t.committed, msg.Offset+1)
+					m.mu.Unlock()
+					acked = true
+				},
+			})
+			if !acked {
+				return
+			}
+			next++
+			continue
+		}
+		published := t.published
+		m.mu.Unlock()
+
+		select {
+		case <-published:
+		case <-ctx.Done():
+			return
+		case <-m.closed:
+			return
+		}
+	}
+}
+
+// Messages возвращает копию сообщений топика
+func (m *Memory) Messages(topic string) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.topics[topic]
+	if !ok {
+		return nil
+	}
+	messages := make([]Message, len(t.messages))
+	for i, msg := range t.messages {
+		messages[i] = cloneMessage(msg)
+	}
+	return messages
+}
+
+// Committed возвращает смещение, с которого продолжится чтение топика
+func (m *Memory) Committed(topic string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.topics[topic]; ok {
+		return t.committed
+	}
+	return 0
+}
+
+// Close останавливает подписки; новые сообщения больше не принимаются
+func (m *Memory) Close() error {
+	m.closeOnce.Do(func() { close(m.closed) })
+	return nil
+}
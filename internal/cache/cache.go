@@ -3,35 +3,121 @@ package cache
 import (
 	"github.com/ZnNr/WB-test-L0/internal/models"
 	"sync"
+	"sync/atomic"
 )
 
 type Cache struct {
 	mu     sync.RWMutex
 	Orders map[string]models.Order
+
+	// Ограничения кэша; нулевое значение означает отсутствие ограничения
+	maxEntries int
+	maxBytes   int64
+	policy     evictionPolicy
+	sizes      map[string]int64
+	bytes      int64
+	evictions  uint64
+
+	// Счётчики обращений; меняются под блокировкой на чтение, поэтому атомарные
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// Options ограничения кэша и политика вытеснения
+type Options struct {
+	MaxEntries int
+	MaxBytes   int64
+	Policy     Policy
+}
+
+// Stats текущее состояние кэша
+type Stats struct {
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	Evictions uint64 `json:"evictions"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
 }
 
 // New создает новый кэш с возможностью задания начальной ёмкости
 func New(initialCapacity int) *Cache {
 	return &Cache{
 		Orders: make(map[string]models.Order, initialCapacity),
+		sizes:  make(map[string]int64, initialCapacity),
 	}
 }
 
+// NewBounded создает кэш, который вытесняет заказы по выбранной политике,
+// когда превышено число записей или оценочный объём памяти
+func NewBounded(opts Options) (*Cache, error) {
+	policy, err := newPolicy(opts.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	c := New(0)
+	c.maxEntries = opts.MaxEntries
+	c.maxBytes = opts.MaxBytes
+	c.policy = policy
+	return c, nil
+}
+
 // SaveOrder сохраняет заказ в кэш
 func (c *Cache) SaveOrder(order models.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+
+	size := estimateSize(order)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		// Заказ не помещается в кэш целиком; старую версию тоже убираем, чтобы не отдавать устаревшие данные
+		c.remove(order.OrderUID)
+		return
+	}
+
+	_, exists := c.Orders[order.OrderUID]
+	if c.policy != nil && !exists {
+		// Освобождаем место до вставки, чтобы новый заказ сам не оказался кандидатом на вытеснение
+		c.evict(1, size)
+	}
+
+	c.bytes += size - c.sizes[order.OrderUID]
+	c.sizes[order.OrderUID] = size
 	c.Orders[order.OrderUID] = order
+	if c.policy != nil {
+		c.policy.add(order.OrderUID)
+		c.evict(0, 0)
+	}
 }
 
 // GetOrder получает заказ из кэша по UID
 func (c *Cache) GetOrder(OrderUID string) (models.Order, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	if c.policy == nil {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		order, ok := c.Orders[OrderUID]
+		c.countLookup(ok)
+		return order, ok
+	}
+
+	// Обращение меняет состояние политики вытеснения, поэтому нужна блокировка на запись
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	order, ok := c.Orders[OrderUID]
+	if ok {
+		c.policy.touch(OrderUID)
+	}
+	c.countLookup(ok)
 	return order, ok
 }
 
+func (c *Cache) countLookup(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
 func (c *Cache) OrderExists(orderUID string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -44,7 +130,7 @@ func (c *Cache) OrderExists(orderUID string) bool {
 func (c *Cache) RemoveOrder(OrderUID string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.Orders, OrderUID)
+	c.remove(OrderUID)
 }
 
 // Clear очищает кэш
@@ -52,6 +138,11 @@ func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.Orders = make(map[string]models.Order)
+	c.sizes = make(map[string]int64)
+	c.bytes = 0
+	if c.policy != nil {
+		c.policy.reset()
+	}
 }
 
 // GetAllOrders возвращает список всех заказов
@@ -64,3 +155,48 @@ func (c *Cache) GetAllOrders() []models.Order {
 	}
 	return orders
 }
+
+// Bounded сообщает, ограничен ли кэш; в ограниченном кэше может быть не всё, что есть в БД
+func (c *Cache) Bounded() bool {
+	return c.policy != nil
+}
+
+// Stats возвращает размер кэша, число вытесненных заказов, попаданий и промахов
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Stats{
+		Entries:   len(c.Orders),
+		Bytes:     c.bytes,
+		Evictions: c.evictions,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+	}
+}
+
+// remove удаляет заказ; вызывается под блокировкой
+func (c *Cache) remove(orderUID string) {
+	if _, ok := c.Orders[orderUID]; !ok {
+		return
+	}
+	delete(c.Orders, orderUID)
+	c.bytes -= c.sizes[orderUID]
+	delete(c.sizes, orderUID)
+	if c.policy != nil {
+		c.policy.remove(orderUID)
+	}
+}
+
+// evict вытесняет заказы, пока кэш с учётом ещё extraEntries записей и extraBytes байт
+// не уложится в ограничения; вызывается под блокировкой
+func (c *Cache) evict(extraEntries int, extraBytes int64) {
+	for (c.maxEntries > 0 && len(c.Orders)+extraEntries > c.maxEntries) ||
+		(c.maxBytes > 0 && c.bytes+extraBytes > c.maxBytes) {
+		victim, ok := c.policy.victim()
+		if !ok {
+			return
+		}
+		c.remove(victim)
+		c.evictions++
+	}
+}
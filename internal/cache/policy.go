@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// Policy политика вытеснения заказов из ограниченного кэша
+type Policy string
+
+const (
+	// PolicyLRU вытесняет заказ, к которому дольше всех не обращались
+	PolicyLRU Policy = "lru"
+	// PolicyLFU вытесняет заказ, к которому обращались реже всех
+	PolicyLFU Policy = "lfu"
+)
+
+// evictionPolicy отслеживает обращения к ключам и выбирает ключ для вытеснения.
+// Методы вызываются под блокировкой кэша.
+type evictionPolicy interface {
+	add(key string)
+	touch(key string)
+	remove(key string)
+	victim() (string, bool)
+	reset()
+}
+
+func newPolicy(policy Policy) (evictionPolicy, error) {
+	switch policy {
+	case "", PolicyLRU:
+		return newLRU(), nil
+	case PolicyLFU:
+		return newLFU(), nil
+	default:
+		return nil, fmt.Errorf("unknown cache eviction policy %q", policy)
+	}
+}
+
+// lru список ключей от самого свежего к самому старому
+type lru struct {
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{order: list.New(), elements: make(map[string]*list.Element)}
+}
+
+func (p *lru) add(key string) {
+	if el, ok := p.elements[key]; ok {
+		p.order.MoveToFront(el)
+		return
+	}
+	p.elements[key] = p.order.PushFront(key)
+}
+
+func (p *lru) touch(key string) {
+	if el, ok := p.elements[key]; ok {
+		p.order.MoveToFront(el)
+	}
+}
+
+func (p *lru) remove(key string) {
+	if el, ok := p.elements[key]; ok {
+		p.order.Remove(el)
+		delete(p.elements, key)
+	}
+}
+
+func (p *lru) victim() (string, bool) {
+	el := p.order.Back()
+	if el == nil {
+		return "", false
+	}
+	return el.Value.(string), true
+}
+
+func (p *lru) reset() {
+	p.order.Init()
+	p.elements = make(map[string]*list.Element)
+}
+
+// lfu группирует ключи по числу обращений; внутри группы вытесняется самый старый ключ
+type lfu struct {
+	buckets  map[int]*list.List
+	elements map[string]*list.Element
+	minFreq  int
+}
+
+type lfuEntry struct {
+	key  string
+	freq int
+}
+
+func newLFU() *lfu {
+	return &lfu{buckets: make(map[int]*list.List), elements: make(map[string]*list.Element)}
+}
+
+func (p *lfu) add(key string) {
+	if _, ok := p.elements[key]; ok {
+		p.touch(key)
+		return
+	}
+	p.elements[key] = p.bucket(1).PushFront(&lfuEntry{key: key, freq: 1})
+	p.minFreq = 1
+}
+
+func (p *lfu) touch(key string) {
+	el, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	entry := el.Value.(*lfuEntry)
+	p.unlink(el, entry.freq)
+	entry.freq++
+	p.elements[key] = p.bucket(entry.freq).PushFront(entry)
+}
+
+func (p *lfu) remove(key string) {
+	el, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	p.unlink(el, el.Value.(*lfuEntry).freq)
+	delete(p.elements, key)
+}
+
+func (p *lfu) victim() (string, bool) {
+	if len(p.elements) == 0 {
+		return "", false
+	}
+	// minFreq может указывать на опустевшую группу после удалений
+	for p.buckets[p.minFreq] == nil {
+		p.minFreq++
+	}
+	return p.buckets[p.minFreq].Back().Value.(*lfuEntry).key, true
+}
+
+func (p *lfu) reset() {
+	p.buckets = make(map[int]*list.List)
+	p.elements = make(map[string]*list.Element)
+	p.minFreq = 0
+}
+
+func (p *lfu) bucket(freq int) *list.List {
+	bucket, ok := p.buckets[freq]
+	if !ok {
+		bucket = list.New()
+		p.buckets[freq] = bucket
+	}
+	return bucket
+}
+
+// unlink убирает элемент из группы и удаляет опустевшую группу
+func (p *lfu) unlink(el *list.Element, freq int) {
+	bucket := p.buckets[freq]
+	bucket.Remove(el)
+	if bucket.Len() == 0 {
+		delete(p.buckets, freq)
+		if p.minFreq == freq {
+			p.minFreq++
+		}
+	}
+}
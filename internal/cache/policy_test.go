@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// LRU evicts the order that was read least recently
+func TestBoundedCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	// Arrange
+	c, err := NewBounded(Options{MaxEntries: 2, Policy: PolicyLRU})
+	require.NoError(t, err)
+	c.SaveOrder(models.Order{OrderUID: "1"})
+	c.SaveOrder(models.Order{OrderUID: "2"})
+	c.GetOrder("1")
+
+	// Act
+	c.SaveOrder(models.Order{OrderUID: "3"})
+
+	// Assert
+	assert.True(t, c.OrderExists("1"))
+	assert.False(t, c.OrderExists("2"), "Least recently used order should be evicted")
+	assert.True(t, c.OrderExists("3"))
+	assert.Equal(t, uint64(1), c.Stats().Evictions)
+}
+
+// LFU evicts the order that was read least often
+func TestBoundedCacheEvictsLeastFrequentlyUsed(t *testing.T) {
+	// Arrange
+	c, err := NewBounded(Options{MaxEntries: 2, Policy: PolicyLFU})
+	require.NoError(t, err)
+	c.SaveOrder(models.Order{OrderUID: "1"})
+	c.SaveOrder(models.Order{OrderUID: "2"})
+	c.GetOrder("1")
+	c.GetOrder("1")
+	c.GetOrder("2")
+
+	// Act
+	c.SaveOrder(models.Order{OrderUID: "3"})
+
+	// Assert
+	assert.True(t, c.OrderExists("1"))
+	assert.False(t, c.OrderExists("2"), "Least frequently used order should be evicted")
+	assert.True(t, c.OrderExists("3"))
+}
+
+// The byte budget evicts orders even when the entry limit is not reached
+func TestBoundedCacheRespectsByteBudget(t *testing.T) {
+	// Arrange
+	order := models.Order{OrderUID: "1", Items: []models.Item{{Name: "item"}}}
+	size := estimateSize(order)
+	c, err := NewBounded(Options{MaxEntries: 100, MaxBytes: size * 2, Policy: PolicyLRU})
+	require.NoError(t, err)
+
+	// Act
+	c.SaveOrder(order)
+	order.OrderUID = "2"
+	c.SaveOrder(order)
+	order.OrderUID = "3"
+	c.SaveOrder(order)
+
+	// Assert
+	stats := c.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.LessOrEqual(t, stats.Bytes, size*2)
+	assert.False(t, c.OrderExists("1"))
+}
+
+// Replacing, removing and clearing keep the byte estimate consistent
+func TestBoundedCacheTracksBytes(t *testing.T) {
+	// Arrange
+	c, err := NewBounded(Options{Policy: PolicyLFU})
+	require.NoError(t, err)
+	order := models.Order{OrderUID: "1"}
+
+	// Act & Assert
+	c.SaveOrder(order)
+	c.SaveOrder(order)
+	assert.Equal(t, estimateSize(order), c.Stats().Bytes)
+
+	c.RemoveOrder("1")
+	assert.Equal(t, int64(0), c.Stats().Bytes)
+
+	c.SaveOrder(order)
+	c.Clear()
+	assert.Equal(t, Stats{}, c.Stats())
+}
+
+// Unknown policies are rejected
+func TestNewBoundedRejectsUnknownPolicy(t *testing.T) {
+	_, err := NewBounded(Options{Policy: "fifo"})
+
+	assert.Error(t, err)
+}
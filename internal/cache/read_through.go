@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+)
+
+// maxMissingEntries ограничивает число запомненных отсутствующих UID
+const maxMissingEntries = 10000
+
+// Loader источник заказов, к которому ReadThrough обращается при промахе кэша.
+// GetOrder возвращает nil без ошибки, если заказа нет.
+type Loader interface {
+	GetOrder(ctx context.Context, orderUID string) (*models.Order, error)
+}
+
+// ReadThrough читает заказы из кэша, а при промахе загружает их из Loader
+// и сохраняет в кэш. Одновременные промахи по одному UID объединяются в один запрос,
+// а отсутствующие UID запоминаются на negativeTTL.
+type ReadThrough struct {
+	cache       *Cache
+	loader      Loader
+	negativeTTL time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]*loadCall
+	missing  map[string]time.Time
+}
+
+// loadCall загрузка заказа, результата которой ждут все одновременные запросы
+type loadCall struct {
+	done  chan struct{}
+	order *models.Order
+	err   error
+	// invalidated заказ изменился во время загрузки, результат нельзя класть в кэш
+	invalidated bool
+}
+
+// NewReadThrough создаёт read-through слой поверх кэша
+func NewReadThrough(cache *Cache, loader Loader, negativeTTL time.Duration) *ReadThrough {
+	return &ReadThrough{
+		cache:       cache,
+		loader:      loader,
+		negativeTTL: negativeTTL,
+		inflight:    make(map[string]*loadCall),
+		missing:     make(map[string]time.Time),
+	}
+}
+
+// GetOrder возвращает заказ из кэша или из Loader; found == false, если заказа нет нигде.
+// Отмена ctx прекращает ожидание, но не прерывает загрузку, которую ждут другие запросы.
+func (r *ReadThrough) GetOrder(ctx context.Context, orderUID string) (models.Order, bool, error) {
+	if order, ok := r.cache.GetOrder(orderUID); ok {
+		return order, true, nil
+	}
+
+	r.mu.Lock()
+	if expiresAt, ok := r.missing[orderUID]; ok {
+		if time.Now().Before(expiresAt) {
+			r.mu.Unlock()
+			return models.Order{}, false, nil
+		}
+		delete(r.missing, orderUID)
+	}
+
+	call, ok := r.inflight[orderUID]
+	if !ok {
+		call = &loadCall{done: make(chan struct{})}
+		r.inflight[orderUID] = call
+		r.mu.Unlock()
+		go r.load(context.WithoutCancel(ctx), orderUID, call)
+	} else {
+		r.mu.Unlock()
+	}
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return models.Order{}, false, ctx.Err()
+	}
+
+	if call.err != nil {
+		return models.Order{}, false, call.err
+	}
+	if call.order == nil {
+		return models.Order{}, false, nil
+	}
+	return *call.order, true, nil
+}
+
+// load загружает заказ и будит всех, кто ждёт этого же UID
+func (r *ReadThrough) load(ctx context.Context, orderUID string, call *loadCall) {
+	order, err := r.loader.GetOrder(ctx, orderUID)
+	if err != nil {
+		call.err = fmt.Errorf("failed to load order %s: %w", orderUID, err)
+	} else {
+		call.order = order
+	}
+
+	r.mu.Lock()
+	delete(r.inflight, orderUID)
+	if order != nil && !call.invalidated {
+		r.cache.SaveOrder(*order)
+	}
+	if err == nil && order == nil && r.negativeTTL > 0 {
+		r.rememberMissing(orderUID)
+	}
+	r.mu.Unlock()
+
+	close(call.done)
+}
+
+// rememberMissing запоминает отсутствующий UID; вызывается под r.mu.
+// Когда отметок становится много, просроченные удаляются, чтобы случайные UID не раздували карту.
+func (r *ReadThrough) rememberMissing(orderUID string) {
+	now := time.Now()
+	if len(r.missing) >= maxMissingEntries {
+		for uid, expiresAt := range r.missing {
+			if !now.Before(expiresAt) {
+				delete(r.missing, uid)
+			}
+		}
+	}
+	if len(r.missing) < maxMissingEntries {
+		r.missing[orderUID] = now.Add(r.negativeTTL)
+	}
+}
+
+// Invalidate забывает заказ в кэше и отметку о его отсутствии,
+// чтобы следующее чтение обратилось к Loader. Вызывается после изменения заказа в БД.
+func (r *ReadThrough) Invalidate(orderUID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache.RemoveOrder(orderUID)
+	delete(r.missing, orderUID)
+	if call, ok := r.inflight[orderUID]; ok {
+		call.invalidated = true
+	}
+}
+
+// InvalidateAll очищает кэш и все отметки об отсутствии заказов.
+func (r *ReadThrough) InvalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache.Clear()
+	clear(r.missing)
+	for _, call := range r.inflight {
+		call.invalidated = true
+	}
+}
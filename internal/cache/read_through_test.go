@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubLoader struct {
+	calls  atomic.Int32
+	delay  time.Duration
+	orders map[string]models.Order
+	err    error
+}
+
+func (l *stubLoader) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	l.calls.Add(1)
+	time.Sleep(l.delay)
+	if l.err != nil {
+		return nil, l.err
+	}
+	order, ok := l.orders[orderUID]
+	if !ok {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+// A miss is loaded from the repository and stored in the cache
+func TestReadThroughLoadsAndPopulatesOnMiss(t *testing.T) {
+	// Arrange
+	c := New(10)
+	loader := &stubLoader{orders: map[string]models.Order{"123": {OrderUID: "123"}}}
+	reader := NewReadThrough(c, loader, time.Minute)
+
+	// Act
+	order, found, err := reader.GetOrder(context.Background(), "123")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "123", order.OrderUID)
+	assert.True(t, c.OrderExists("123"), "Loaded order should be cached")
+
+	_, _, _ = reader.GetOrder(context.Background(), "123")
+	assert.Equal(t, int32(1), loader.calls.Load(), "Second read should be served from the cache")
+}
+
+// Concurrent misses for the same UID collapse into one load
+func TestReadThroughCollapsesConcurrentMisses(t *testing.T) {
+	// Arrange
+	c := New(10)
+	loader := &stubLoader{delay: 50 * time.Millisecond, orders: map[string]models.Order{"123": {OrderUID: "123"}}}
+	reader := NewReadThrough(c, loader, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, found, err := reader.GetOrder(context.Background(), "123")
+			assert.NoError(t, err)
+			assert.True(t, found)
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	assert.Equal(t, int32(1), loader.calls.Load())
+}
+
+// Unknown UIDs are remembered for the negative TTL
+func TestReadThroughCachesNegativeResults(t *testing.T) {
+	// Arrange
+	c := New(10)
+	loader := &stubLoader{orders: map[string]models.Order{}}
+	reader := NewReadThrough(c, loader, 50*time.Millisecond)
+
+	// Act
+	_, found, err := reader.GetOrder(context.Background(), "unknown")
+	_, _, _ = reader.GetOrder(context.Background(), "unknown")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, int32(1), loader.calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	_, _, _ = reader.GetOrder(context.Background(), "unknown")
+	assert.Equal(t, int32(2), loader.calls.Load(), "Expired negative entry should trigger a new load")
+}
+
+// Loader errors are returned and not cached as missing orders
+func TestReadThroughReturnsLoaderErrors(t *testing.T) {
+	// Arrange
+	c := New(10)
+	loader := &stubLoader{err: errors.New("db is down")}
+	reader := NewReadThrough(c, loader, time.Minute)
+
+	// Act
+	_, found, err := reader.GetOrder(context.Background(), "123")
+	_, _, _ = reader.GetOrder(context.Background(), "123")
+
+	// Assert
+	assert.Error(t, err)
+	assert.False(t, found)
+	assert.Equal(t, int32(2), loader.calls.Load())
+}
+
+// Invalidate forgets a negative result so a restored order is visible immediately
+func TestReadThroughInvalidateForgetsMissing(t *testing.T) {
+	// Arrange
+	c := New(10)
+	loader := &stubLoader{orders: map[string]models.Order{}}
+	reader := NewReadThrough(c, loader, time.Minute)
+	_, found, _ := reader.GetOrder(context.Background(), "123")
+	assert.False(t, found)
+
+	// Act
+	loader.orders["123"] = models.Order{OrderUID: "123"}
+	reader.Invalidate("123")
+	_, found, err := reader.GetOrder(context.Background(), "123")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, found)
+}
+
+// An order deleted while it is being loaded is not put back into the cache
+func TestReadThroughInvalidateDuringLoad(t *testing.T) {
+	// Arrange
+	c := New(10)
+	loader := &stubLoader{delay: 50 * time.Millisecond, orders: map[string]models.Order{"123": {OrderUID: "123"}}}
+	reader := NewReadThrough(c, loader, time.Minute)
+
+	// Act
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = reader.GetOrder(context.Background(), "123")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	reader.Invalidate("123")
+	<-done
+
+	// Assert
+	assert.False(t, c.OrderExists("123"))
+}
+
+// A cancelled caller stops waiting, while the shared load still completes and fills the cache
+func TestReadThroughCancelledCallerStopsWaiting(t *testing.T) {
+	// Arrange
+	c := New(10)
+	loader := &stubLoader{delay: 100 * time.Millisecond, orders: map[string]models.Order{"123": {OrderUID: "123"}}}
+	reader := NewReadThrough(c, loader, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act
+	start := time.Now()
+	_, found, err := reader.GetOrder(ctx, "123")
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, found)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	order, found, err := reader.GetOrder(context.Background(), "123")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "123", order.OrderUID)
+	assert.Equal(t, int32(1), loader.calls.Load(), "Second caller should join the load started by the cancelled one")
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"unsafe"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+)
+
+// entryOverhead примерные накладные расходы на запись карты и учёт политики вытеснения
+const entryOverhead = 128
+
+// estimateSize оценивает объём памяти, занимаемый заказом в кэше
+func estimateSize(order models.Order) int64 {
+	size := int64(unsafe.Sizeof(order)) + entryOverhead
+	size += int64(len(order.OrderUID)*2 + len(order.TrackNumber) + len(order.Entry) + len(order.Locale) +
+		len(order.InternalSignature) + len(order.CustomerID) + len(order.DeliveryService) +
+		len(order.Shardkey) + len(order.OofShard))
+
+	delivery := order.Delivery
+	size += int64(len(delivery.OrderUID) + len(delivery.Name) + len(delivery.Phone) + len(delivery.Zip) +
+		len(delivery.City) + len(delivery.Address) + len(delivery.Region) + len(delivery.Email))
+
+	payment := order.Payment
+	size += int64(len(payment.Transaction) + len(payment.RequestID) + len(payment.Currency) +
+		len(payment.Provider) + len(payment.Bank))
+
+	size += int64(cap(order.Items)) * int64(unsafe.Sizeof(models.Item{}))
+	for _, item := range order.Items {
+		size += int64(len(item.TrackNumber) + len(item.Rid) + len(item.Name) + len(item.Size) + len(item.Brand))
+	}
+	return size
+}
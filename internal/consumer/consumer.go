@@ -2,90 +2,74 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"github.com/IBM/sarama"
+	"github.com/ZnNr/WB-test-L0/internal/broker"
 	"github.com/ZnNr/WB-test-L0/internal/cache"
-	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/ZnNr/WB-test-L0/internal/envelope"
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
 	"github.com/ZnNr/WB-test-L0/internal/repository"
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
 	"go.uber.org/zap"
 	"sync"
+	"time"
 )
 
-// Subscribe подписывается на сообщения Kafka и обрабатывает их.
-func Subscribe(ctx context.Context, cache *cache.Cache, db *repository.OrdersRepo, logger *zap.Logger, wg *sync.WaitGroup) error {
+// Subscribe подписывается на топик заказов в брокере b и обрабатывает сообщения до отмены контекста.
+// Сообщения, которые не удалось обработать, отправляются в deadLetters.
+// Состояние подписки отражается в status, если он задан.
+func Subscribe(ctx context.Context, cfg config.KafkaConfig, b broker.Broker, cache *cache.Cache, invalidator Invalidator, db repository.Orders, deadLetters *dlq.Queue, status *Status, logger *zap.Logger, wg *sync.WaitGroup) error {
 	defer wg.Done() // Убедимся, что wait group завершится
-	topic := "orders"
 
-	worker, err := ConnectConsumer([]string{"localhost:9092"})
-	if err != nil {
-		return fmt.Errorf("failed to connect consumer: %w", err)
-	}
-	defer func() {
-		if err := worker.Close(); err != nil {
-			logger.Error("Failed to close consumer", zap.Error(err))
-		}
-	}()
+	status.set(StateConnecting, nil)
+	handler := newGroupHandler(NewRegistry(cache, invalidator, db, logger), deadLetters, cfg.MaxAttempts, status, logger)
 
-	consumer, err := worker.ConsumePartition(topic, 0, sarama.OffsetNewest)
-	if err != nil {
-		return fmt.Errorf("consume_partition failed: %w", err)
-	}
-	defer func() {
-		if err := consumer.Close(); err != nil {
-			logger.Error("Failed to close partition consumer", zap.Error(err))
-		}
-	}()
+	logger.Info("Consumer subscribed to broker",
+		zap.String("topic", cfg.Topic),
+		zap.String("group_id", cfg.GroupID),
+	)
 
-	logger.Info("Consumer subscribed to Kafka!", zap.String("topic", topic))
+	if err := b.Subscribe(ctx, cfg.Topic, handler); err != nil {
+		status.set(StateFailed, err)
+		return fmt.Errorf("consume failed: %w", err)
+	}
+	status.set(StateStopped, nil)
+	logger.Info("Shutting down consumer")
+	return nil
+}
 
-	go func() {
-		for {
-			select {
-			case err := <-consumer.Errors():
-				logger.Error("Consuming error", zap.Error(err))
-			case msg := <-consumer.Messages():
-				handleMessage(msg, cache, db, logger)
-			case <-ctx.Done():
-				logger.Info("Shutting down consumer")
-				return
-			}
+// handleMessage разбирает сообщение в событие и передаёт его обработчику из реестра.
+// Tombstone (пустое значение с order_uid в ключе) обрабатывается как безвозвратное удаление заказа.
+// Ошибка типа *dlq.Failure указывает этап, на котором сообщение не удалось обработать;
+// пропущенные сообщения ошибкой не считаются. Отмена ctx прерывает запросы к БД.
+func handleMessage(ctx context.Context, msg *broker.Message, registry *Registry, logger *zap.Logger) (err error) {
+	start := time.Now()
+	result := metrics.ResultConsumed
+	defer func() {
+		if err != nil {
+			result = metrics.ResultFailed
 		}
+		metrics.ConsumerMessages.WithLabelValues(result).Inc()
+		metrics.ConsumerProcessingSeconds.WithLabelValues(result).ObserveSince(start)
 	}()
 
-	<-ctx.Done() // Ожидаем завершения по сигналу из контекста
-	return nil
-}
-
-// handleMessage обрабатывает сообщение из Kafka.
-func handleMessage(msg *sarama.ConsumerMessage, cache *cache.Cache, db *repository.OrdersRepo, logger *zap.Logger) {
-	if len(msg.Value) == 0 {
+	var event *envelope.Envelope
+	_, binary := msg.Header(envelope.HeaderPrefix + "specversion")
+	switch {
+	case len(msg.Value) == 0 && !binary && len(msg.Key) == 0:
 		logger.Warn("Received empty message, skipping")
-		return
-	}
-
-	var order models.Order
-	if err := json.Unmarshal(msg.Value, &order); err != nil {
-		logger.Error("Failed to unmarshal message", zap.Error(err), zap.ByteString("message", msg.Value))
-		return
-	}
-
-	if _, found := cache.GetOrder(order.OrderUID); found {
-		logger.Info("Order exists, skipping", zap.String("order_uid", order.OrderUID))
-		return
-	}
-
-	if err := db.AddOrder(order); err != nil {
-		logger.Error("Failed to save order to DB", zap.Error(err), zap.String("order_uid", order.OrderUID))
-		return
+		result = metrics.ResultSkipped
+		return nil
+	case len(msg.Value) == 0 && !binary:
+		event = tombstone(string(msg.Key))
+	default:
+		event, err = envelope.Decode(msg)
+		if err != nil {
+			logger.Error("Failed to decode message", zap.Error(err), zap.ByteString("message", msg.Value))
+			return dlq.Fail(dlq.StageDecode, fmt.Errorf("failed to decode message: %w", err))
+		}
 	}
 
-	cache.SaveOrder(order)
-	logger.Info("Consumed order", zap.String("order_uid", order.OrderUID))
-}
-
-func ConnectConsumer(brokers []string) (sarama.Consumer, error) {
-	config := sarama.NewConfig()
-	config.Version = sarama.V1_0_0_0
-	return sarama.NewConsumer(brokers, config)
+	result, err = registry.handle(ctx, event)
+	return err
 }
@@ -2,10 +2,20 @@ package consumer
 
 import (
 	"context"
-	"github.com/IBM/sarama"
+	"encoding/json"
+	"errors"
+	"github.com/ZnNr/WB-test-L0/internal/broker"
 	"github.com/ZnNr/WB-test-L0/internal/cache"
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/ZnNr/WB-test-L0/internal/envelope"
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/order_gen"
 	"github.com/ZnNr/WB-test-L0/internal/repository"
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+	"github.com/ZnNr/WB-test-L0/internal/validation"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"go.uber.org/zap"
 	"sync"
@@ -13,193 +23,555 @@ import (
 	"time"
 )
 
-// Successfully connects to Kafka broker and subscribes to the topic
-func TestSubscribeConnectsAndSubscribes(t *testing.T) {
+func testKafkaConfig() config.KafkaConfig {
+	return config.KafkaConfig{
+		Brokers:           []string{"localhost:9092"},
+		Topic:             "orders",
+		GroupID:           "orders-service-test",
+		InitialOffset:     "oldest",
+		RebalanceStrategy: "range",
+		DLQTopic:          "orders.dlq",
+		MaxAttempts:       3,
+	}
+}
+
+// subscribeInBackground runs Subscribe against the broker and returns a function that stops it
+func subscribeInBackground(t *testing.T, b broker.Broker, cache *cache.Cache, db repository.Orders, status *Status, logger *zap.Logger) func() error {
+	t.Helper()
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	cache := cache.New(10)
-	db := &repository.OrdersRepo{}
-	logger := zap.NewExample()
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
+	done := make(chan error, 1)
+	go func() { done <- Subscribe(ctx, testKafkaConfig(), b, cache, nil, db, nil, status, logger, wg) }()
+	return func() error {
+		cancel()
+		wg.Wait()
+		return <-done
+	}
+}
+
+// Subscribes to the broker topic and reports the consumer as ready
+func TestSubscribeConnectsAndSubscribes(t *testing.T) {
+	// Arrange
+	b := broker.NewMemory()
+	status := NewStatus()
+	logger := zap.NewNop()
 
 	// Act
-	err := Subscribe(ctx, cache, db, logger, wg)
+	stop := subscribeInBackground(t, b, cache.New(10), repository.NewMemoryOrdersRepo(), status, logger)
 
 	// Assert
-	assert.NoError(t, err)
-	assert.Contains(t, logger.Check(zap.InfoLevel, "Consumer subscribed to Kafka!").Message, "Consumer subscribed to Kafka!")
+	assert.Eventually(t, func() bool { return status.Check(context.Background()) == nil }, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, stop())
+	state, _ := status.State()
+	assert.Equal(t, StateStopped, state)
 }
 
-// Correctly processes messages from Kafka and updates cache and database
+// Correctly processes messages from the broker and updates cache and database
 func TestSubscribeProcessesMessages(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	cache := cache.New(10)
-	db := &repository.OrdersRepo{}
-	logger := zap.NewExample()
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-
 	// Arrange
-	go func() {
-		// Simulate message processing
-		time.Sleep(1 * time.Second)
-		cancel()
-	}()
+	b := broker.NewMemory()
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+	payload, _ := json.Marshal(order)
+	require.NoError(t, b.Publish(context.Background(), &broker.Message{Topic: testKafkaConfig().Topic, Value: payload}))
 
 	// Act
-	err := Subscribe(ctx, cache, db, logger, wg)
+	stop := subscribeInBackground(t, b, cache, db, nil, zap.NewNop())
+	require.Eventually(t, func() bool { return b.Committed(testKafkaConfig().Topic) == 1 }, 5*time.Second, 10*time.Millisecond)
 
 	// Assert
-	assert.NoError(t, err)
-	assert.NotEmpty(t, cache.Orders)
+	assert.NoError(t, stop())
+	assert.True(t, cache.OrderExists(order.OrderUID))
+	stored, err := db.GetOrder(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
 }
 
-// Receives an empty message and logs a warning
+// Acknowledges an empty message without storing anything
 func TestSubscribeReceivesEmptyMessage(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	cache := cache.New(10)
-	db := &repository.OrdersRepo{}
-	logger := zap.NewExample()
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-
 	// Arrange
-	go func() {
-		time.Sleep(1 * time.Second)
-		cancel()
-	}()
+	b := broker.NewMemory()
+	cache := cache.New(10)
+	require.NoError(t, b.Publish(context.Background(), &broker.Message{Topic: testKafkaConfig().Topic}))
 
 	// Act
-	err := Subscribe(ctx, cache, db, logger, wg)
+	stop := subscribeInBackground(t, b, cache, repository.NewMemoryOrdersRepo(), nil, zap.NewNop())
+	require.Eventually(t, func() bool { return b.Committed(testKafkaConfig().Topic) == 1 }, 5*time.Second, 10*time.Millisecond)
 
 	// Assert
-	assert.NoError(t, err)
-	assert.Contains(t, logger.Check(zap.WarnLevel, "Received empty message").Message, "Received empty message")
+	assert.NoError(t, stop())
+	assert.Zero(t, cache.Stats().Entries)
+}
+
+// failingBroker reports a consuming error to the handler and fails the subscription
+type failingBroker struct {
+	*broker.Memory
+	err error
 }
 
-// Encounters an error when consuming messages and logs the error
+func (b failingBroker) Subscribe(_ context.Context, _ string, handler broker.Handler) error {
+	handler.Error(b.err)
+	return b.err
+}
+
+// Encounters an error when consuming messages and marks the consumer as failed
 func TestSubscribeLogsConsumingError(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	cache := cache.New(10)
-	db := &repository.OrdersRepo{}
-	logger := zap.NewExample()
+	// Arrange
+	b := failingBroker{Memory: broker.NewMemory(), err: errors.New("broker is down")}
+	status := NewStatus()
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 
-	// Arrange
-	go func() {
-		time.Sleep(1 * time.Second)
-		cancel()
-	}()
-
 	// Act
-	err := Subscribe(ctx, cache, db, logger, wg)
+	err := Subscribe(context.Background(), testKafkaConfig(), b, cache.New(10), nil, repository.NewMemoryOrdersRepo(), nil, status, zap.NewNop(), wg)
 
 	// Assert
-	assert.NoError(t, err)
-	assert.Contains(t, logger.Check(zap.ErrorLevel, "Consuming error").Message, "Consuming error")
+	assert.ErrorIs(t, err, b.err)
+	state, _ := status.State()
+	assert.Equal(t, StateFailed, state)
+	assert.ErrorIs(t, status.Check(context.Background()), b.err)
 }
 
 // Receives an empty message and logs a warning
 func TestHandleEmptyMessage(t *testing.T) {
 	cache := cache.New(10)
-	db := &repository.OrdersRepo{}
+	db := repository.NewMemoryOrdersRepo()
 	logger := zap.NewExample()
 
-	msg := &sarama.ConsumerMessage{Value: []byte{}}
+	msg := &broker.Message{Value: []byte{}}
 
-	handleMessage(msg, cache, db, logger)
+	handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, logger), logger)
 
 	// Check logs for warning about empty message
 	logs := logger.Check(zap.WarnLevel, "Received empty message, skipping")
 	assert.NotNil(t, logs)
 }
 
-// Successfully connects to a Kafka broker with valid broker addresses
-func TestConnectConsumerWithValidBrokers(t *testing.T) {
+// Reports undecodable payloads as non-retryable decode failures
+func TestHandleMessageReportsDecodeFailure(t *testing.T) {
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	logger := zap.NewExample()
+
+	msg := &broker.Message{Value: []byte("not json")}
+
+	err := handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, logger), logger)
+
+	failure := dlq.AsFailure(err)
+	assert.Equal(t, dlq.StageDecode, failure.Stage)
+	assert.False(t, failure.Retryable())
+}
+
+// Continues the attempt count carried by a redriven message
+func TestAttemptsReadsRedriveHeader(t *testing.T) {
+	msg := &broker.Message{Headers: []broker.Header{{Key: dlq.HeaderAttempts, Value: "4"}}}
+
+	assert.Equal(t, 4, dlq.Attempts(msg))
+	assert.Equal(t, 0, dlq.Attempts(&broker.Message{}))
+}
+
+// Reports not ready until partitions are assigned
+func TestStatusCheckRequiresConsumingState(t *testing.T) {
+	status := NewStatus()
+	assert.Error(t, status.Check(context.Background()))
+
+	status.set(StateConsuming, nil)
+	assert.NoError(t, status.Check(context.Background()))
+
+	status.set(StateRebalancing, nil)
+	assert.Error(t, status.Check(context.Background()))
+}
+
+// Skips versions that are not newer than the cached order without touching the database
+func TestHandleMessageSkipsStaleVersion(t *testing.T) {
+	cache := cache.New(10)
+	order := order_gen.GenerateOrder()
+	order.Version = 3
+	cache.SaveOrder(order)
+	db := repository.NewMemoryOrdersRepo()
+	logger := zap.NewNop()
+
+	order.Version = 2
+	payload, _ := json.Marshal(order)
+	msg := &broker.Message{Value: payload}
+
+	err := handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, logger), logger)
+
+	assert.NoError(t, err)
+	cached, _ := cache.GetOrder(order.OrderUID)
+	assert.Equal(t, int64(3), cached.Version)
+}
+
+// Skips new versions of a soft-deleted order and keeps it out of the cache
+func TestHandleMessageSkipsDeletedOrder(t *testing.T) {
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+	require.NoError(t, db.AddOrder(context.Background(), order))
+	require.NoError(t, db.SoftDeleteOrder(context.Background(), order.OrderUID))
+	payload, _ := json.Marshal(order)
+
+	err := handleMessage(context.Background(), &broker.Message{Value: payload}, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop())
+
+	assert.NoError(t, err)
+	assert.False(t, cache.OrderExists(order.OrderUID))
+	stored, err := db.GetOrder(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+// slowLoader returns the order as it was when the load started, after a delay
+type slowLoader struct {
+	db    repository.Orders
+	delay time.Duration
+}
+
+func (l *slowLoader) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	order, err := l.db.GetOrder(ctx, orderUID)
+	time.Sleep(l.delay)
+	return order, err
+}
+
+// A read-through load started before a delete does not put the deleted order back into the cache
+func TestHandleMessageDeleteInvalidatesReadThrough(t *testing.T) {
 	// Arrange
-	brokers := []string{"localhost:9092"}
+	c := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+	require.NoError(t, db.AddOrder(context.Background(), order))
+	orders := cache.NewReadThrough(c, &slowLoader{db: db, delay: 50 * time.Millisecond}, time.Minute)
+	registry := NewRegistry(c, orders, db, zap.NewNop())
 
 	// Act
-	consumer, err := ConnectConsumer(brokers)
+	loaded := make(chan struct{})
+	go func() {
+		defer close(loaded)
+		_, _, _ = orders.GetOrder(context.Background(), order.OrderUID)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	err := handleMessage(context.Background(), &broker.Message{Key: []byte(order.OrderUID)}, registry, zap.NewNop())
+	<-loaded
 
 	// Assert
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
-	}
-	if consumer == nil {
-		t.Fatal("Expected a non-nil consumer")
-	}
+	require.NoError(t, err)
+	assert.False(t, c.OrderExists(order.OrderUID))
 }
 
-// Returns a sarama.Consumer object when connection is successful
-func TestReturnsConsumerObjectOnSuccess(t *testing.T) {
+// Reports orders that fail validation as non-retryable validation failures before touching the database
+func TestHandleMessageReportsValidationFailure(t *testing.T) {
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	logger := zap.NewNop()
+
+	msg := &broker.Message{Value: []byte(`{"order_uid":"123","version":2}`)}
+
+	err := handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, logger), logger)
+
+	failure := dlq.AsFailure(err)
+	assert.Equal(t, dlq.StageValidate, failure.Stage)
+	assert.False(t, failure.Retryable())
+	var invalid validation.Errors
+	assert.ErrorAs(t, err, &invalid)
+	assert.False(t, cache.OrderExists("123"))
+}
+
+// Stores a valid order in the repository and the cache, and skips a replay of the same version
+func TestHandleMessageStoresOrder(t *testing.T) {
 	// Arrange
-	brokers := []string{"localhost:9092"}
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	logger := zap.NewNop()
+	order := order_gen.GenerateOrder()
+	payload, _ := json.Marshal(order)
+	msg := &broker.Message{Value: payload}
 
 	// Act
-	consumer, err := ConnectConsumer(brokers)
+	err := handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, logger), logger)
 
 	// Assert
-	if err != nil {
-		t.Fatalf("Expected no error, got %v", err)
-	}
-	if _, ok := consumer.(sarama.Consumer); !ok {
-		t.Fatal("Expected a sarama.Consumer object")
-	}
+	require.NoError(t, err)
+	stored, err := db.GetOrder(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, int64(1), stored.Version)
+	cached, found := cache.GetOrder(order.OrderUID)
+	assert.True(t, found)
+	assert.Equal(t, order.Items, cached.Items)
+
+	order.Version = 1
+	payload, _ = json.Marshal(order)
+	assert.NoError(t, handleMessage(context.Background(), &broker.Message{Value: payload}, NewRegistry(cache, nil, db, logger), logger))
+	history, err := db.GetOrderHistory(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	assert.Empty(t, history)
 }
 
-// Handles empty broker list gracefully
-func TestConnectConsumerWithEmptyBrokerList(t *testing.T) {
+// Reports a cancelled store as a store failure instead of saving the order
+func TestHandleMessageRespectsCancellation(t *testing.T) {
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	payload, _ := json.Marshal(order_gen.GenerateOrder())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := handleMessage(ctx, &broker.Message{Value: payload}, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop())
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, dlq.StageStore, dlq.AsFailure(err).Stage)
+	assert.Zero(t, cache.Stats().Entries)
+}
+
+// storeOrder saves a generated order through handleMessage and returns it
+func storeOrder(t *testing.T, cache *cache.Cache, db repository.Orders) models.Order {
+	t.Helper()
+	order := order_gen.GenerateOrder()
+	payload, _ := json.Marshal(order)
+	require.NoError(t, handleMessage(context.Background(), &broker.Message{Key: []byte(order.OrderUID), Value: payload}, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop()))
+	require.True(t, cache.OrderExists(order.OrderUID))
+	return order
+}
+
+// A tombstone keyed by order_uid removes the order for good, and a replay is a no-op
+func TestHandleMessageTombstoneDeletesOrder(t *testing.T) {
 	// Arrange
-	brokers := []string{}
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	order := storeOrder(t, cache, db)
+	msg := &broker.Message{Key: []byte(order.OrderUID)}
 
 	// Act
-	consumer, err := ConnectConsumer(brokers)
+	err := handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop())
 
 	// Assert
-	if err == nil {
-		t.Fatal("Expected an error for empty broker list")
-	}
-	if consumer != nil {
-		t.Fatal("Expected a nil consumer for empty broker list")
-	}
+	require.NoError(t, err)
+	assert.False(t, cache.OrderExists(order.OrderUID))
+	assert.ErrorIs(t, db.RestoreOrder(context.Background(), order.OrderUID), repository.ErrOrderNotFound)
+	assert.NoError(t, handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop()))
+}
+
+// An order.deleted envelope marks the order deleted so it can be restored later
+func TestHandleMessageDeleteEventSoftDeletesOrder(t *testing.T) {
+	// Arrange
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	order := storeOrder(t, cache, db)
+	msg := &broker.Message{Value: []byte(`{"type":"order.deleted","order_uid":"` + order.OrderUID + `"}`)}
+
+	// Act
+	err := handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop())
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, cache.OrderExists(order.OrderUID))
+	stored, err := db.GetOrder(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	assert.NoError(t, handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop()))
+	assert.NoError(t, db.RestoreOrder(context.Background(), order.OrderUID))
 }
 
-// Returns an error if broker addresses are invalid
-func TestConnectConsumerWithInvalidBrokers(t *testing.T) {
+// A hard order.deleted envelope without order_uid deletes the order named by the message key
+func TestHandleMessageHardDeleteEventUsesKey(t *testing.T) {
 	// Arrange
-	brokers := []string{"invalid-broker-address"}
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	order := storeOrder(t, cache, db)
+	msg := &broker.Message{Key: []byte(order.OrderUID), Value: []byte(`{"type":"order.deleted","hard":true}`)}
 
 	// Act
-	consumer, err := ConnectConsumer(brokers)
+	err := handleMessage(context.Background(), msg, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop())
 
 	// Assert
-	if err == nil {
-		t.Fatal("Expected an error for invalid broker addresses")
+	require.NoError(t, err)
+	assert.False(t, cache.OrderExists(order.OrderUID))
+	assert.ErrorIs(t, db.RestoreOrder(context.Background(), order.OrderUID), repository.ErrOrderNotFound)
+}
+
+// Deleting an order that was never stored still clears a stale cache entry
+func TestHandleMessageDeleteClearsStaleCache(t *testing.T) {
+	cache := cache.New(10)
+	order := order_gen.GenerateOrder()
+	cache.SaveOrder(order)
+
+	err := handleMessage(context.Background(), &broker.Message{Key: []byte(order.OrderUID)}, NewRegistry(cache, nil, repository.NewMemoryOrdersRepo(), zap.NewNop()), zap.NewNop())
+
+	assert.NoError(t, err)
+	assert.False(t, cache.OrderExists(order.OrderUID))
+}
+
+// Rejects envelopes of unknown types and delete events that don't name an order
+func TestHandleMessageRejectsInvalidEvents(t *testing.T) {
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+
+	err := handleMessage(context.Background(), &broker.Message{Value: []byte(`{"type":"order.archived","order_uid":"1"}`)}, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop())
+	assert.Equal(t, dlq.StageDecode, dlq.AsFailure(err).Stage)
+
+	err = handleMessage(context.Background(), &broker.Message{Value: []byte(`{"type":"order.deleted"}`)}, NewRegistry(cache, nil, db, zap.NewNop()), zap.NewNop())
+	assert.Equal(t, dlq.StageValidate, dlq.AsFailure(err).Stage)
+	assert.False(t, dlq.AsFailure(err).Retryable())
+}
+
+// Reports a failed delete as a retryable store failure
+func TestHandleMessageReportsDeleteFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := handleMessage(ctx, &broker.Message{Key: []byte("123")}, NewRegistry(cache.New(10), nil, repository.NewMemoryOrdersRepo(), zap.NewNop()), zap.NewNop())
+
+	assert.ErrorIs(t, err, context.Canceled)
+	failure := dlq.AsFailure(err)
+	assert.Equal(t, dlq.StageStore, failure.Stage)
+	assert.True(t, failure.Retryable())
+}
+
+// Stores orders delivered as CloudEvents in binary and structured mode
+func TestHandleMessageStoresCloudEvents(t *testing.T) {
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	registry := NewRegistry(cache, nil, db, zap.NewNop())
+
+	created := order_gen.GenerateOrder()
+	event, err := envelope.New(envelope.TypeOrderCreated, created.OrderUID, created)
+	require.NoError(t, err)
+	require.NoError(t, handleMessage(context.Background(), event.Binary("orders"), registry, zap.NewNop()))
+
+	updated := created
+	updated.CustomerID = "customer-2"
+	event, err = envelope.New(envelope.TypeOrderUpdated, updated.OrderUID, updated)
+	require.NoError(t, err)
+	msg, err := event.Structured("orders")
+	require.NoError(t, err)
+	require.NoError(t, handleMessage(context.Background(), msg, registry, zap.NewNop()))
+
+	stored, err := db.GetOrder(context.Background(), created.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, "customer-2", stored.CustomerID)
+	assert.Equal(t, int64(2), stored.Version)
+	cached, _ := cache.GetOrder(created.OrderUID)
+	assert.Equal(t, int64(2), cached.Version)
+}
+
+// Dispatches events to handlers registered for their type
+func TestRegistryDispatchesByType(t *testing.T) {
+	// Arrange
+	registry := NewRegistry(cache.New(10), nil, repository.NewMemoryOrdersRepo(), zap.NewNop())
+	var received *envelope.Envelope
+	registry.Register(envelope.TypeOrderCancelled, func(ctx context.Context, event *envelope.Envelope) (string, error) {
+		received = event
+		return metrics.ResultConsumed, nil
+	})
+	event, err := envelope.New(envelope.TypeOrderCancelled, "123", map[string]string{"reason": "customer"})
+	require.NoError(t, err)
+
+	// Act
+	err = handleMessage(context.Background(), event.Binary("orders"), registry, zap.NewNop())
+
+	// Assert
+	require.NoError(t, err)
+	require.NotNil(t, received)
+	assert.Equal(t, event.ID, received.ID)
+	assert.Equal(t, "123", received.OrderUID())
+	assert.Contains(t, registry.Types(), envelope.TypeOrderCancelled)
+}
+
+// Sends events without a handler, from a newer schema or about another order to the dead letter queue
+func TestHandleMessageRejectsUnsupportedCloudEvents(t *testing.T) {
+	registry := NewRegistry(cache.New(10), nil, repository.NewMemoryOrdersRepo(), zap.NewNop())
+	order := order_gen.GenerateOrder()
+
+	unknown, err := envelope.New("order.archived", order.OrderUID, order)
+	require.NoError(t, err)
+	newer, err := envelope.New(envelope.TypeOrderCreated, order.OrderUID, order)
+	require.NoError(t, err)
+	newer.SchemaVersion = envelope.SchemaVersion + 1
+	mismatch, err := envelope.New(envelope.TypeOrderCreated, "another-order", order)
+	require.NoError(t, err)
+
+	tests := []struct {
+		event *envelope.Envelope
+		stage dlq.Stage
+	}{
+		{unknown, dlq.StageDecode},
+		{newer, dlq.StageDecode},
+		{mismatch, dlq.StageValidate},
 	}
-	if consumer != nil {
-		t.Fatal("Expected a nil consumer for invalid broker addresses")
+	for _, tt := range tests {
+		err := handleMessage(context.Background(), tt.event.Binary("orders"), registry, zap.NewNop())
+
+		failure := dlq.AsFailure(err)
+		assert.Equal(t, tt.stage, failure.Stage, tt.event.Type)
+		assert.False(t, failure.Retryable())
 	}
 }
 
-// Manages network failures or broker unavailability
-func TestConnectConsumerWithUnavailableBroker(t *testing.T) {
+// Moves an order through its lifecycle from status events and keeps the cache in sync
+func TestHandleMessageChangesOrderStatus(t *testing.T) {
 	// Arrange
-	brokers := []string{"unavailable-broker-address"}
+	cache := cache.New(10)
+	db := repository.NewMemoryOrdersRepo()
+	registry := NewRegistry(cache, nil, db, zap.NewNop())
+	order := order_gen.GenerateOrder()
+	require.NoError(t, db.AddOrder(context.Background(), order))
+	paidAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	paid, err := envelope.New(envelope.TypeOrderStatusChanged, order.OrderUID, map[string]any{"status": "paid", "changed_at": paidAt})
+	require.NoError(t, err)
+	cancelled, err := envelope.New(envelope.TypeOrderCancelled, order.OrderUID, map[string]string{"reason": "customer"})
+	require.NoError(t, err)
 
 	// Act
-	consumer, err := ConnectConsumer(brokers)
+	paidErr := handleMessage(context.Background(), paid.Binary("orders"), registry, zap.NewNop())
+	repeatErr := handleMessage(context.Background(), paid.Binary("orders"), registry, zap.NewNop())
+	cancelledErr := handleMessage(context.Background(), cancelled.Binary("orders"), registry, zap.NewNop())
 
 	// Assert
-	if err == nil {
-		t.Fatal("Expected an error for unavailable broker")
+	require.NoError(t, paidErr)
+	require.NoError(t, repeatErr)
+	require.NoError(t, cancelledErr)
+	cached, found := cache.GetOrder(order.OrderUID)
+	require.True(t, found)
+	assert.Equal(t, models.StatusCancelled, cached.Status)
+
+	timeline, err := db.GetOrderTimeline(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	require.Len(t, timeline, 3)
+	assert.Equal(t, models.StatusChange{Status: models.StatusPaid, ChangedAt: paidAt}, timeline[1])
+	assert.Equal(t, models.StatusCancelled, timeline[2].Status)
+	assert.Equal(t, "customer", timeline[2].Reason)
+	assert.True(t, cancelled.Time.Equal(timeline[2].ChangedAt))
+}
+
+// Rejects unknown statuses and forbidden transitions, and retries events for orders that don't exist yet
+func TestHandleMessageRejectsInvalidStatusEvents(t *testing.T) {
+	db := repository.NewMemoryOrdersRepo()
+	registry := NewRegistry(cache.New(10), nil, db, zap.NewNop())
+	order := order_gen.GenerateOrder()
+	require.NoError(t, db.AddOrder(context.Background(), order))
+
+	newEvent := func(orderUID, status string) *envelope.Envelope {
+		event, err := envelope.New(envelope.TypeOrderStatusChanged, orderUID, map[string]string{"status": status})
+		require.NoError(t, err)
+		return event
+	}
+	tests := []struct {
+		event *envelope.Envelope
+		stage dlq.Stage
+	}{
+		{newEvent(order.OrderUID, "lost"), dlq.StageValidate},
+		{newEvent(order.OrderUID, "delivered"), dlq.StageValidate},
+		{newEvent("unknown", "paid"), dlq.StageStore},
 	}
-	if consumer != nil {
-		t.Fatal("Expected a nil consumer for unavailable broker")
+	for _, tt := range tests {
+		err := handleMessage(context.Background(), tt.event.Binary("orders"), registry, zap.NewNop())
+
+		failure := dlq.AsFailure(err)
+		require.NotNil(t, failure, string(tt.event.Data))
+		assert.Equal(t, tt.stage, failure.Stage, string(tt.event.Data))
 	}
+
+	stored, err := db.GetOrder(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCreated, stored.Status)
 }
@@ -0,0 +1,163 @@
+package consumer
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/broker"
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	retryInitialBackoff = 500 * time.Millisecond
+	retryMaxBackoff     = 30 * time.Second
+)
+
+// groupHandler реализует broker.Handler.
+// Сообщение подтверждается только после того, как handleMessage обработал событие,
+// поэтому после перезапуска чтение продолжается с первого необработанного сообщения.
+// Сообщения, которые нельзя обработать повторно или которые исчерпали maxAttempts,
+// отправляются в dead-letter топик.
+type groupHandler struct {
+	registry    *Registry
+	deadLetters *dlq.Queue
+	maxAttempts int
+	status      *Status
+	logger      *zap.Logger
+}
+
+func newGroupHandler(registry *Registry, deadLetters *dlq.Queue, maxAttempts int, status *Status, logger *zap.Logger) *groupHandler {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &groupHandler{registry: registry, deadLetters: deadLetters, maxAttempts: maxAttempts, status: status, logger: logger}
+}
+
+// Started вызывается в начале сеанса чтения.
+func (h *groupHandler) Started() {
+	h.status.set(StateConsuming, nil)
+	h.logger.Info("Consumer session started")
+}
+
+// Stopped вызывается в конце сеанса, когда подтверждённые сообщения уже закоммичены.
+func (h *groupHandler) Stopped() {
+	h.status.set(StateRebalancing, nil)
+	h.logger.Info("Consumer session finished")
+}
+
+// Error запоминает ошибку брокера, после которой чтение продолжается.
+func (h *groupHandler) Error(err error) {
+	h.status.recordError(err)
+	h.logger.Error("Consuming error", zap.Error(err))
+}
+
+// Handle обрабатывает сообщение и подтверждает его, когда заказ сохранён или сообщение ушло в карантин.
+func (h *groupHandler) Handle(ctx context.Context, d broker.Delivery) {
+	if !h.process(ctx, d.Message) {
+		// Сеанс завершается раньше, чем заказ удалось сохранить:
+		// сообщение будет прочитано повторно
+		d.Nack()
+		return
+	}
+	d.Ack()
+	if d.Lag >= 0 {
+		metrics.ConsumerLag.WithLabelValues(d.Topic, strconv.Itoa(int(d.Partition))).Set(float64(d.Lag))
+	}
+}
+
+// process повторяет обработку сообщения с экспоненциальной задержкой, пока заказ
+// не будет сохранён или сообщение не окажется в dead-letter топике.
+// Возвращает false, если сеанс завершился раньше.
+func (h *groupHandler) process(ctx context.Context, msg *broker.Message) bool {
+	// Учитываем попытки, сделанные до повторной отправки сообщения из карантина
+	attempts := dlq.Attempts(msg)
+	backoff := retryInitialBackoff
+	for try := 1; ; try++ {
+		err := handleMessage(ctx, msg, h.registry, h.logger)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			// Запрос прерван остановкой сеанса, а не ошибкой сообщения
+			return false
+		}
+		attempts++
+
+		failure := dlq.AsFailure(err)
+		if !failure.Retryable() || try >= h.maxAttempts {
+			return h.deadLetter(ctx, msg, failure, attempts)
+		}
+
+		h.logger.Warn("Retrying message",
+			zap.Error(err),
+			zap.String("topic", msg.Topic),
+			zap.Int32("partition", msg.Partition),
+			zap.Int64("offset", msg.Offset),
+			zap.Int("attempts", attempts),
+			zap.Duration("backoff", backoff),
+		)
+
+		if !sleep(ctx, backoff) {
+			return false
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// deadLetter отправляет сообщение в dead-letter топик, повторяя отправку, пока она не удастся.
+// Возвращает false, если сеанс завершился раньше.
+func (h *groupHandler) deadLetter(ctx context.Context, msg *broker.Message, failure *dlq.Failure, attempts int) bool {
+	if h.deadLetters == nil {
+		h.logger.Error("Dead letter queue is not configured, dropping message",
+			zap.Error(failure),
+			zap.String("topic", msg.Topic),
+			zap.Int32("partition", msg.Partition),
+			zap.Int64("offset", msg.Offset),
+		)
+		return true
+	}
+
+	backoff := retryInitialBackoff
+	for {
+		letter, err := h.deadLetters.Send(ctx, msg, failure, attempts)
+		if err == nil {
+			h.logger.Warn("Message moved to dead letter queue",
+				zap.Int64("dead_letter_id", letter.ID),
+				zap.String("stage", letter.Stage),
+				zap.String("error", letter.Error),
+				zap.String("topic", msg.Topic),
+				zap.Int32("partition", msg.Partition),
+				zap.Int64("offset", msg.Offset),
+				zap.Int("attempts", attempts),
+			)
+			return true
+		}
+
+		h.logger.Error("Failed to move message to dead letter queue", zap.Error(err), zap.Duration("backoff", backoff))
+		if !sleep(ctx, backoff) {
+			return false
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// sleep ждёт d или завершения сеанса; возвращает false, если сеанс завершился.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > retryMaxBackoff {
+		return retryMaxBackoff
+	}
+	return backoff
+}
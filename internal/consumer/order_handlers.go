@@ -0,0 +1,208 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/cache"
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/ZnNr/WB-test-L0/internal/envelope"
+	"github.com/ZnNr/WB-test-L0/internal/events"
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/repository"
+	"github.com/ZnNr/WB-test-L0/internal/validation"
+	"go.uber.org/zap"
+)
+
+// orderHandlers обработчики событий, которые меняют заказ в БД и кэше
+type orderHandlers struct {
+	cache       *cache.Cache
+	invalidator Invalidator
+	db          repository.Orders
+	logger      *zap.Logger
+}
+
+// invalidate сбрасывает заказ в кэше перед записью нового состояния
+func (h *orderHandlers) invalidate(orderUID string) {
+	if h.invalidator != nil {
+		h.invalidator.Invalidate(orderUID)
+		return
+	}
+	h.cache.RemoveOrder(orderUID)
+}
+
+// tombstone событие безвозвратного удаления заказа, которым становится пустое сообщение с order_uid в ключе
+func tombstone(orderUID string) *envelope.Envelope {
+	return &envelope.Envelope{
+		SpecVersion:   envelope.SpecVersion,
+		Type:          envelope.TypeOrderDeleted,
+		SchemaVersion: envelope.SchemaVersion,
+		PartitionKey:  orderUID,
+		Data:          json.RawMessage(`{"hard":true}`),
+		Mode:          envelope.ModeLegacy,
+	}
+}
+
+// save сохраняет заказ из data событий order.created и order.updated
+func (h *orderHandlers) save(ctx context.Context, event *envelope.Envelope) (string, error) {
+	var order models.Order
+	if err := json.Unmarshal(event.Data, &order); err != nil {
+		h.logger.Error("Failed to unmarshal order", zap.Error(err), zap.String("type", event.Type), zap.ByteString("data", event.Data))
+		return "", dlq.Fail(dlq.StageDecode, fmt.Errorf("failed to unmarshal order: %w", err))
+	}
+	if err := validation.ValidateOrder(order); err != nil {
+		h.logger.Error("Invalid order", zap.Error(err), zap.String("order_uid", order.OrderUID))
+		return "", dlq.Fail(dlq.StageValidate, err)
+	}
+	if event.Subject != "" && event.Subject != order.OrderUID {
+		err := fmt.Errorf("subject: %q does not match order_uid %q", event.Subject, order.OrderUID)
+		h.logger.Error("Invalid event", zap.Error(err), zap.String("id", event.ID))
+		return "", dlq.Fail(dlq.StageValidate, err)
+	}
+
+	// Версию, не новее закэшированной, отклоняем без обращения к БД
+	if cached, found := h.cache.GetOrder(order.OrderUID); found && order.Version != 0 && order.Version <= cached.Version {
+		h.logger.Info("Stale order version, skipping",
+			zap.String("order_uid", order.OrderUID),
+			zap.Int64("version", order.Version),
+			zap.Int64("stored_version", cached.Version),
+		)
+		return metrics.ResultSkipped, nil
+	}
+
+	stored, err := h.db.UpsertOrder(ctx, order)
+	if err != nil {
+		if errors.Is(err, repository.ErrStaleVersion) {
+			h.logger.Info("Stale order version, skipping", zap.String("order_uid", order.OrderUID), zap.Error(err))
+			return metrics.ResultSkipped, nil
+		}
+		if errors.Is(err, repository.ErrOrderDeleted) {
+			// Удалённый заказ не возвращаем в кэш: его может вернуть только восстановление
+			h.invalidate(order.OrderUID)
+			h.logger.Info("Order is deleted, skipping", zap.String("order_uid", order.OrderUID))
+			return metrics.ResultSkipped, nil
+		}
+		h.logger.Error("Failed to save order to DB", zap.Error(err), zap.String("order_uid", order.OrderUID))
+		return "", dlq.Fail(dlq.StageStore, fmt.Errorf("failed to save order %s: %w", order.OrderUID, err))
+	}
+
+	h.invalidate(stored.OrderUID)
+	h.cache.SaveOrder(*stored)
+	h.logger.Info("Consumed order",
+		zap.String("order_uid", stored.OrderUID),
+		zap.Int64("version", stored.Version),
+		zap.String("type", event.Type),
+	)
+	return metrics.ResultConsumed, nil
+}
+
+// delete удаляет заказ по событию order.deleted: безвозвратно, если в data указано hard, иначе помечает удалённым.
+// Data события — events.Event; без order_uid в data заказ берётся из subject или ключа сообщения.
+// Повторное удаление уже удалённого заказа не считается ошибкой, поэтому событие можно обрабатывать несколько раз.
+func (h *orderHandlers) delete(ctx context.Context, event *envelope.Envelope) (string, error) {
+	var deleted events.Event
+	if len(event.Data) > 0 {
+		if err := json.Unmarshal(event.Data, &deleted); err != nil {
+			h.logger.Error("Failed to unmarshal event", zap.Error(err), zap.ByteString("data", event.Data))
+			return "", dlq.Fail(dlq.StageDecode, fmt.Errorf("failed to unmarshal event: %w", err))
+		}
+	}
+	orderUID := deleted.OrderUID
+	if orderUID == "" {
+		orderUID = event.OrderUID()
+	}
+	if orderUID == "" {
+		h.logger.Error("Invalid event", zap.String("type", event.Type), zap.String("id", event.ID))
+		return "", dlq.Fail(dlq.StageValidate, errors.New("order_uid: is required"))
+	}
+
+	var err error
+	if deleted.Hard {
+		err = h.db.DeleteOrder(ctx, orderUID)
+	} else {
+		err = h.db.SoftDeleteOrder(ctx, orderUID)
+	}
+	// Заказ убираем из кэша и тогда, когда в БД его уже нет: кэш мог отстать от БД
+	if err == nil || errors.Is(err, repository.ErrOrderNotFound) {
+		h.invalidate(orderUID)
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound):
+		h.logger.Info("Order already deleted, skipping", zap.String("order_uid", orderUID), zap.Bool("hard", deleted.Hard))
+		return metrics.ResultSkipped, nil
+	case err != nil:
+		h.logger.Error("Failed to delete order from DB", zap.Error(err), zap.String("order_uid", orderUID))
+		return "", dlq.Fail(dlq.StageStore, fmt.Errorf("failed to delete order %s: %w", orderUID, err))
+	}
+
+	h.logger.Info("Deleted order", zap.String("order_uid", orderUID), zap.Bool("hard", deleted.Hard))
+	return metrics.ResultDeleted, nil
+}
+
+// statusEvent data событий order.status_changed и order.cancelled
+type statusEvent struct {
+	OrderUID  string             `json:"order_uid"`
+	Status    models.OrderStatus `json:"status"`
+	ChangedAt time.Time          `json:"changed_at"`
+	Reason    string             `json:"reason"`
+}
+
+// changeStatus переводит заказ в статус из data события order.status_changed; order.cancelled всегда отменяет заказ.
+// Время перехода берётся из changed_at, а без него — из времени события.
+// Недопустимый переход в карантин уходит сразу, а заказ, которого ещё нет, обрабатывается повторно:
+// событие о статусе могло обогнать событие о создании заказа.
+func (h *orderHandlers) changeStatus(ctx context.Context, event *envelope.Envelope) (string, error) {
+	var data statusEvent
+	if len(event.Data) > 0 {
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			h.logger.Error("Failed to unmarshal event", zap.Error(err), zap.ByteString("data", event.Data))
+			return "", dlq.Fail(dlq.StageDecode, fmt.Errorf("failed to unmarshal event: %w", err))
+		}
+	}
+	if event.Type == envelope.TypeOrderCancelled {
+		data.Status = models.StatusCancelled
+	}
+	orderUID := data.OrderUID
+	if orderUID == "" {
+		orderUID = event.OrderUID()
+	}
+
+	var err error
+	switch {
+	case orderUID == "":
+		err = errors.New("order_uid: is required")
+	case !data.Status.Valid():
+		err = fmt.Errorf("status: unknown order status %q", data.Status)
+	case event.Subject != "" && event.Subject != orderUID:
+		err = fmt.Errorf("subject: %q does not match order_uid %q", event.Subject, orderUID)
+	}
+	if err != nil {
+		h.logger.Error("Invalid event", zap.Error(err), zap.String("type", event.Type), zap.String("id", event.ID))
+		return "", dlq.Fail(dlq.StageValidate, err)
+	}
+
+	change := models.StatusChange{Status: data.Status, ChangedAt: data.ChangedAt.UTC(), Reason: data.Reason}
+	if change.ChangedAt.IsZero() && event.Time != nil {
+		change.ChangedAt = event.Time.UTC()
+	}
+
+	order, err := h.db.ChangeOrderStatus(ctx, orderUID, change)
+	switch {
+	case errors.Is(err, models.ErrInvalidTransition):
+		h.logger.Error("Invalid status transition", zap.Error(err), zap.String("order_uid", orderUID))
+		return "", dlq.Fail(dlq.StageValidate, err)
+	case err != nil:
+		h.logger.Error("Failed to change order status in DB", zap.Error(err), zap.String("order_uid", orderUID))
+		return "", dlq.Fail(dlq.StageStore, fmt.Errorf("failed to change status of order %s: %w", orderUID, err))
+	}
+
+	h.invalidate(orderUID)
+	h.cache.SaveOrder(*order)
+	h.logger.Info("Changed order status", zap.String("order_uid", orderUID), zap.String("status", string(order.Status)))
+	return metrics.ResultConsumed, nil
+}
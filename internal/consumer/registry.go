@@ -0,0 +1,67 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/ZnNr/WB-test-L0/internal/cache"
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/ZnNr/WB-test-L0/internal/envelope"
+	"github.com/ZnNr/WB-test-L0/internal/repository"
+	"go.uber.org/zap"
+)
+
+// EventHandler обрабатывает событие одного типа и возвращает результат для метрик:
+// metrics.ResultConsumed, metrics.ResultSkipped или metrics.ResultDeleted.
+// Ошибка, как и у handleMessage, должна быть *dlq.Failure.
+type EventHandler func(ctx context.Context, event *envelope.Envelope) (string, error)
+
+// Invalidator сбрасывает заказ в кэше так, чтобы загрузка из БД, начатая до изменения,
+// не вернула в кэш прежний заказ; его реализует cache.ReadThrough
+type Invalidator interface {
+	Invalidate(orderUID string)
+}
+
+// Registry обработчики событий по их типу
+type Registry struct {
+	handlers map[string]EventHandler
+}
+
+// NewRegistry создаёт реестр с обработчиками событий о заказах, которые сохраняют изменения в db и cache.
+// Изменённые заказы сбрасываются через invalidator; без него — только в cache.
+func NewRegistry(cache *cache.Cache, invalidator Invalidator, db repository.Orders, logger *zap.Logger) *Registry {
+	r := &Registry{handlers: make(map[string]EventHandler)}
+	orders := &orderHandlers{cache: cache, invalidator: invalidator, db: db, logger: logger}
+	r.Register(envelope.TypeOrderCreated, orders.save)
+	r.Register(envelope.TypeOrderUpdated, orders.save)
+	r.Register(envelope.TypeOrderStatusChanged, orders.changeStatus)
+	r.Register(envelope.TypeOrderCancelled, orders.changeStatus)
+	r.Register(envelope.TypeOrderDeleted, orders.delete)
+	return r
+}
+
+// Register задаёт обработчик событий типа eventType, заменяя прежний
+func (r *Registry) Register(eventType string, handler EventHandler) {
+	r.handlers[eventType] = handler
+}
+
+// Types возвращает типы событий, для которых есть обработчики
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.handlers))
+	for eventType := range r.handlers {
+		types = append(types, eventType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// handle передаёт событие обработчику его типа. Событие неизвестного типа повторно не обрабатывается:
+// оно уходит в карантин, откуда его можно отправить заново, когда появится обработчик.
+func (r *Registry) handle(ctx context.Context, event *envelope.Envelope) (string, error) {
+	handler, ok := r.handlers[event.Type]
+	if !ok {
+		return "", dlq.Fail(dlq.StageDecode, fmt.Errorf("unsupported event type %q", event.Type))
+	}
+	return handler(ctx, event)
+}
@@ -0,0 +1,77 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// State состояние подписки на Kafka
+type State string
+
+const (
+	StateConnecting  State = "connecting"
+	StateConsuming   State = "consuming"
+	StateRebalancing State = "rebalancing"
+	StateStopped     State = "stopped"
+	StateFailed      State = "failed"
+)
+
+// Status текущее состояние консьюмера для проверки готовности
+type Status struct {
+	mu      sync.RWMutex
+	state   State
+	since   time.Time
+	lastErr error
+}
+
+func NewStatus() *Status {
+	return &Status{state: StateConnecting, since: time.Now()}
+}
+
+// set меняет состояние; nil-статус допустим, чтобы консьюмер работал и без наблюдения
+func (s *Status) set(state State, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != state {
+		s.state = state
+		s.since = time.Now()
+	}
+	if err != nil {
+		s.lastErr = err
+	}
+}
+
+// recordError запоминает ошибку, не меняя состояния
+func (s *Status) recordError(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+}
+
+// State возвращает текущее состояние и время перехода в него
+func (s *Status) State() (State, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state, s.since
+}
+
+// Check реализует health.CheckFunc: консьюмер готов, если получил партиции и читает их
+func (s *Status) Check(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.state == StateConsuming {
+		return nil
+	}
+	if s.lastErr != nil {
+		return fmt.Errorf("consumer is %s since %s: %w", s.state, s.since.Format(time.RFC3339), s.lastErr)
+	}
+	return fmt.Errorf("consumer is %s since %s", s.state, s.since.Format(time.RFC3339))
+}
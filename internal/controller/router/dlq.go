@@ -0,0 +1,86 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDeadLettersLimit = 50
+	maxDeadLettersLimit     = 500
+)
+
+// HandleGetDeadLetters обработчик для получения списка сообщений из карантина
+func (c *Controller) HandleGetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultDeadLettersLimit)
+	if err != nil || limit < 1 || limit > maxDeadLettersLimit {
+		c.writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxDeadLettersLimit))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		c.writeError(w, http.StatusBadRequest, "offset must be a non-negative number")
+		return
+	}
+
+	letters, err := c.DeadLetters.List(r.Context(), limit, offset)
+	if err != nil {
+		c.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.writeJSON(w, http.StatusOK, letters)
+}
+
+// HandleGetDeadLetter обработчик для получения сообщения из карантина по id
+func (c *Controller) HandleGetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		c.writeError(w, http.StatusBadRequest, "invalid dead letter id")
+		return
+	}
+
+	letter, err := c.DeadLetters.Get(r.Context(), id)
+	if err != nil {
+		c.writeDeadLetterError(w, id, err)
+		return
+	}
+	c.writeJSON(w, http.StatusOK, letter)
+}
+
+// HandleRedriveDeadLetter обработчик для повторной отправки сообщения в основной топик
+func (c *Controller) HandleRedriveDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		c.writeError(w, http.StatusBadRequest, "invalid dead letter id")
+		return
+	}
+
+	letter, err := c.DeadLetters.Redrive(r.Context(), id)
+	if err != nil {
+		c.writeDeadLetterError(w, id, err)
+		return
+	}
+	c.writeJSON(w, http.StatusAccepted, letter)
+}
+
+func (c *Controller) writeDeadLetterError(w http.ResponseWriter, id int64, err error) {
+	if errors.Is(err, dlq.ErrNotFound) {
+		c.writeError(w, http.StatusNotFound, fmt.Sprintf("Dead letter <%d> not found!", id))
+		return
+	}
+	c.writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// queryInt читает целочисленный параметр запроса, возвращая def, если он не задан
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -2,45 +2,115 @@ package router
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/handlers"
 	"net/http"
+	"time"
 
+	"github.com/ZnNr/WB-test-L0/internal/auth"
 	"github.com/ZnNr/WB-test-L0/internal/cache"
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/ZnNr/WB-test-L0/internal/events"
+	"github.com/ZnNr/WB-test-L0/internal/health"
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/query"
+	"github.com/ZnNr/WB-test-L0/internal/repository"
+	"github.com/ZnNr/WB-test-L0/internal/validation"
 	"github.com/gorilla/mux"
 )
 
+// maxOrderBytes наибольший размер заказа, принимаемого по HTTP
+const maxOrderBytes = 1 << 20
+
 type Controller struct {
-	Cache *cache.Cache
+	Cache       *cache.Cache
+	Orders      *cache.ReadThrough
+	Repo        repository.Orders
+	DeadLetters *dlq.Queue
+	Health      *health.Checker
+	// Auth проверяет доступ к маршрутам; nil отключает проверку, оставляя доступным только чтение
+	Auth *auth.Authenticator
+	// AllowAnonymous без Auth открывает всем и маршруты operator и admin
+	AllowAnonymous bool
+	// CORSOrigins источники, которым разрешены запросы из браузера
+	CORSOrigins []string
+	// Events получает события об удалении и восстановлении заказов; nil отключает публикацию
+	Events events.Publisher
 }
 
-// Функция для инициализации контроллера с кэшем
-func NewController(cache *cache.Cache) *Controller {
-	return &Controller{Cache: cache}
+// Функция для инициализации контроллера с кэшем, read-through слоем, репозиторием,
+// очередью недоставленных сообщений, проверками готовности, аутентификацией и публикацией событий
+func NewController(cache *cache.Cache, orders *cache.ReadThrough, repo repository.Orders, deadLetters *dlq.Queue, checker *health.Checker, authenticator *auth.Authenticator, allowAnonymous bool, corsOrigins []string, publisher events.Publisher) *Controller {
+	return &Controller{Cache: cache, Orders: orders, Repo: repo, DeadLetters: deadLetters, Health: checker, Auth: authenticator, AllowAnonymous: allowAnonymous, CORSOrigins: corsOrigins, Events: publisher}
 }
 
 // Настройка маршрутизатора
 func (c *Controller) SetupRouter() *mux.Router {
 	r := mux.NewRouter()
 
-	// Настройка CORS
-	corsOptions := handlers.AllowedOrigins([]string{"*"})
+	// Настройка CORS; без настроенных источников gorilla/handlers разрешает любой,
+	// поэтому пустой список явно запрещает все
+	corsOptions := handlers.AllowedOrigins(c.CORSOrigins)
+	if len(c.CORSOrigins) == 0 {
+		corsOptions = handlers.AllowedOriginValidator(func(string) bool { return false })
+	}
 	corsMethods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
-	corsHeaders := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
+	corsHeaders := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", auth.APIKeyHeader})
+
+	// Метрики запросов считаются до CORS, чтобы учитывать и предварительные запросы
+	r.Use(metrics.HTTPMiddleware)
 
 	//Применяем middleware для CORS
 	r.Use(handlers.CORS(corsOptions, corsMethods, corsHeaders))
 	r.Use(c.preflightHandler)
 
-	// Маршруты вашего API
-	r.HandleFunc("/order/{order_uid}", c.HandleGetOrder).Methods(http.MethodGet, http.MethodOptions)
-	r.HandleFunc("/order/{order_uid}", c.HandleDeleteOrder).Methods(http.MethodDelete, http.MethodOptions)
-	r.HandleFunc("/delorders", c.HandleClearOrders).Methods(http.MethodDelete, http.MethodOptions)
-	r.HandleFunc("/orders", c.HandleGetAllOrders).Methods(http.MethodGet, http.MethodOptions)
+	// Маршруты вашего API; чтение доступно reader, удаление и восстановление заказа — operator,
+	// безвозвратное удаление и удаление всех заказов — только admin
+	r.HandleFunc("/order", c.protect(auth.RoleOperator, c.HandlePostOrder)).Methods(http.MethodPost, http.MethodOptions)
+	r.HandleFunc("/order/{order_uid}", c.protect(auth.RoleReader, c.HandleGetOrder)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/order/{order_uid}", c.protect(auth.RoleOperator, c.HandleDeleteOrder)).Methods(http.MethodDelete, http.MethodOptions)
+	r.HandleFunc("/order/{order_uid}/history", c.protect(auth.RoleReader, c.HandleGetOrderHistory)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/order/{order_uid}/timeline", c.protect(auth.RoleReader, c.HandleGetOrderTimeline)).Methods(http.MethodGet, http.MethodOptions)
+	r.HandleFunc("/order/{order_uid}/restore", c.protect(auth.RoleOperator, c.HandleRestoreOrder)).Methods(http.MethodPost, http.MethodOptions)
+	r.HandleFunc("/order/{order_uid}/purge", c.protect(auth.RoleAdmin, c.HandlePurgeOrder)).Methods(http.MethodDelete, http.MethodOptions)
+	r.HandleFunc("/delorders", c.protect(auth.RoleAdmin, c.HandleClearOrders)).Methods(http.MethodDelete, http.MethodOptions)
+	r.HandleFunc("/orders", c.protect(auth.RoleReader, c.HandleGetAllOrders)).Methods(http.MethodGet, http.MethodOptions)
+
+	// Проверки живости и готовности для оркестратора
+	if c.Health != nil {
+		r.HandleFunc("/healthz", c.Health.LivenessHandler).Methods(http.MethodGet)
+		r.HandleFunc("/readyz", c.Health.ReadinessHandler).Methods(http.MethodGet)
+	}
+
+	r.Handle("/metrics", metrics.Default.Handler()).Methods(http.MethodGet)
+
+	// Карантин сообщений, которые не удалось обработать; содержит исходные сообщения,
+	// поэтому доступен с роли operator
+	if c.DeadLetters != nil {
+		r.HandleFunc("/dlq", c.protect(auth.RoleOperator, c.HandleGetDeadLetters)).Methods(http.MethodGet, http.MethodOptions)
+		r.HandleFunc("/dlq/{id:[0-9]+}", c.protect(auth.RoleOperator, c.HandleGetDeadLetter)).Methods(http.MethodGet, http.MethodOptions)
+		r.HandleFunc("/dlq/{id:[0-9]+}/redrive", c.protect(auth.RoleOperator, c.HandleRedriveDeadLetter)).Methods(http.MethodPost, http.MethodOptions)
+	}
 
 	return r
 }
 
+// protect требует для маршрута роль не ниже required, если аутентификация включена.
+// Без аутентификации маршруты operator и admin отвечают 403, пока анонимный доступ не разрешён явно
+func (c *Controller) protect(required auth.Role, handler http.HandlerFunc) http.HandlerFunc {
+	if c.Auth == nil {
+		if c.AllowAnonymous || auth.RoleReader.Allows(required) {
+			return handler
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			c.writeError(w, http.StatusForbidden, "authentication is disabled, this route requires role "+string(required))
+		}
+	}
+	return c.Auth.Require(required, handler)
+}
+
 // Middleware для обработки предварительных запросов
 func (c *Controller) preflightHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -56,7 +126,11 @@ func (c *Controller) preflightHandler(next http.Handler) http.Handler {
 func (c *Controller) HandleGetOrder(w http.ResponseWriter, r *http.Request) {
 	orderUID := mux.Vars(r)["order_uid"]
 
-	order, ok := c.Cache.GetOrder(orderUID)
+	order, ok, err := c.Orders.GetOrder(r.Context(), orderUID)
+	if err != nil {
+		c.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	if !ok {
 		c.writeError(w, http.StatusNotFound, fmt.Sprintf("OrderUID: <%s> not found!", orderUID))
 		return
@@ -64,34 +138,188 @@ func (c *Controller) HandleGetOrder(w http.ResponseWriter, r *http.Request) {
 	c.writeJSON(w, http.StatusOK, order)
 }
 
-// HandleDeleteOrder Обработчик для удаления заказа по order_uid
+// HandlePostOrder Обработчик для приёма заказа по HTTP; заказ проверяется так же, как сообщения из Kafka.
+// На ошибки проверки отвечает 422 со списком полей, на устаревшую версию — 409.
+func (c *Controller) HandlePostOrder(w http.ResponseWriter, r *http.Request) {
+	var order models.Order
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBytes)).Decode(&order); err != nil {
+		c.writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode order: %v", err))
+		return
+	}
+
+	var invalid validation.Errors
+	if err := validation.ValidateOrder(order); errors.As(err, &invalid) {
+		c.writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"error": "invalid order", "fields": invalid})
+		return
+	}
+
+	stored, err := c.Repo.UpsertOrder(r.Context(), order)
+	if err != nil {
+		// Удалённый заказ не перезаписываем: его нужно сначала восстановить
+		if errors.Is(err, repository.ErrStaleVersion) || errors.Is(err, repository.ErrOrderDeleted) {
+			c.writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		c.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.invalidate(stored.OrderUID)
+	c.Cache.SaveOrder(*stored)
+	c.writeJSON(w, http.StatusOK, stored)
+}
+
+// HandleGetOrderHistory Обработчик для получения заменённых версий заказа, начиная с последней
+func (c *Controller) HandleGetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["order_uid"]
+
+	history, err := c.Repo.GetOrderHistory(r.Context(), orderUID)
+	if err != nil {
+		c.writeRepoError(w, orderUID, err)
+		return
+	}
+	c.writeJSON(w, http.StatusOK, history)
+}
+
+// HandleGetOrderTimeline Обработчик для получения переходов заказа между статусами, начиная с создания
+func (c *Controller) HandleGetOrderTimeline(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["order_uid"]
+
+	timeline, err := c.Repo.GetOrderTimeline(r.Context(), orderUID)
+	if err != nil {
+		c.writeRepoError(w, orderUID, err)
+		return
+	}
+	c.writeJSON(w, http.StatusOK, timeline)
+}
+
+// HandleDeleteOrder Обработчик для удаления заказа по order_uid.
+// Заказ помечается удалённым в БД, поэтому не вернётся после перезапуска, но может быть восстановлен.
 func (c *Controller) HandleDeleteOrder(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	orderUID := vars["order_uid"]
+	orderUID := mux.Vars(r)["order_uid"]
 
-	if !c.Cache.OrderExists(orderUID) {
-		c.writeError(w, http.StatusNotFound, fmt.Sprintf("OrderUID: <%s> not found!", orderUID))
+	if err := c.Repo.SoftDeleteOrder(r.Context(), orderUID); err != nil {
+		c.writeRepoError(w, orderUID, err)
 		return
 	}
 
-	c.Cache.RemoveOrder(orderUID)
+	c.invalidate(orderUID)
+	c.publish(r, events.OrderDeleted, orderUID, false)
 	c.writeJSON(w, http.StatusOK, fmt.Sprintf("OrderUID: <%s> successfully deleted", orderUID))
 }
 
-// HandleClearOrders Обработчик для очистки всех заказов
+// HandlePurgeOrder Обработчик для безвозвратного удаления заказа вместе с доставкой, платежом и товарами
+func (c *Controller) HandlePurgeOrder(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["order_uid"]
+
+	if err := c.Repo.DeleteOrder(r.Context(), orderUID); err != nil {
+		c.writeRepoError(w, orderUID, err)
+		return
+	}
+
+	c.invalidate(orderUID)
+	c.publish(r, events.OrderDeleted, orderUID, true)
+	c.writeJSON(w, http.StatusOK, fmt.Sprintf("OrderUID: <%s> permanently deleted", orderUID))
+}
+
+// HandleRestoreOrder Обработчик для восстановления удалённого заказа
+func (c *Controller) HandleRestoreOrder(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["order_uid"]
+
+	if err := c.Repo.RestoreOrder(r.Context(), orderUID); err != nil {
+		c.writeRepoError(w, orderUID, err)
+		return
+	}
+
+	c.invalidate(orderUID)
+	c.publish(r, events.OrderRestored, orderUID, false)
+	c.writeJSON(w, http.StatusOK, fmt.Sprintf("OrderUID: <%s> successfully restored", orderUID))
+}
+
+// HandleClearOrders Обработчик для удаления всех заказов; заказы помечаются удалёнными в БД.
+// Подписчики получают одно событие orders.deleted, а не событие на каждый заказ,
+// чтобы время запроса не зависело от размера таблицы
 func (c *Controller) HandleClearOrders(w http.ResponseWriter, r *http.Request) {
-	c.Cache.Clear()
-	c.writeJSON(w, http.StatusOK, "All orders successfully cleared")
+	deleted, err := c.Repo.SoftDeleteOrders(r.Context())
+	if err != nil {
+		c.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if c.Orders != nil {
+		c.Orders.InvalidateAll()
+	} else {
+		c.Cache.Clear()
+	}
+	if deleted > 0 {
+		c.publishEvent(r, events.Event{Type: events.OrdersDeleted, Count: deleted})
+	}
+	c.writeJSON(w, http.StatusOK, fmt.Sprintf("All orders successfully cleared: %d", deleted))
 }
 
-// HandleGetAllOrders обработчик для получения всех заказов
+// invalidate убирает заказ из кэша после изменения в БД
+func (c *Controller) invalidate(orderUID string) {
+	if c.Orders != nil {
+		c.Orders.Invalidate(orderUID)
+		return
+	}
+	c.Cache.RemoveOrder(orderUID)
+}
+
+// publish сообщает об изменении заказа. Изменение в БД к этому моменту уже выполнено,
+// поэтому ошибка публикации не отменяет запрос; публикатор сам пишет её в журнал
+func (c *Controller) publish(r *http.Request, eventType events.Type, orderUID string, hard bool) {
+	c.publishEvent(r, events.Event{Type: eventType, OrderUID: orderUID, Hard: hard})
+}
+
+// publishEvent заполняет время и инициатора события и отправляет его
+func (c *Controller) publishEvent(r *http.Request, event events.Event) {
+	if c.Events == nil {
+		return
+	}
+	event.OccurredAt = time.Now().UTC()
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		event.Actor = principal.Subject
+	}
+	_ = c.Events.Publish(event)
+}
+
+func (c *Controller) writeRepoError(w http.ResponseWriter, orderUID string, err error) {
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		c.writeError(w, http.StatusNotFound, fmt.Sprintf("OrderUID: <%s> not found!", orderUID))
+		return
+	}
+	c.writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// HandleGetAllOrders обработчик для получения списка заказов с фильтрами, сортировкой и постраничной выдачей.
+// Ограниченный кэш может содержать не все заказы, поэтому тогда список строится по репозиторию.
 func (c *Controller) HandleGetAllOrders(w http.ResponseWriter, r *http.Request) {
-	orders := c.Cache.GetAllOrders() // Метод, который должен вернуть все заказы
-	if len(orders) == 0 {
-		c.writeJSON(w, http.StatusOK, []interface{}{}) // Если заказов нет, возвращаем пустой массив
+	params, err := query.Parse(r.URL.Query())
+	if err != nil {
+		c.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var page query.Page
+	if c.Cache.Bounded() && c.Repo != nil {
+		page, err = c.Repo.FindOrders(r.Context(), params)
+	} else {
+		page, err = query.Apply(c.Cache.GetAllOrders(), params)
+	}
+	if err != nil {
+		if errors.Is(err, query.ErrInvalidCursor) {
+			c.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		c.writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	c.writeJSON(w, http.StatusOK, orders)
+
+	if page.Orders == nil {
+		page.Orders = []models.Order{} // Если заказов нет, возвращаем пустой массив
+	}
+	c.writeJSON(w, http.StatusOK, page)
 }
 
 // Приватные методы для записи JSON и ошибок
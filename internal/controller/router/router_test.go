@@ -0,0 +1,354 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/cache"
+	"github.com/ZnNr/WB-test-L0/internal/events"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/order_gen"
+	"github.com/ZnNr/WB-test-L0/internal/query"
+	"github.com/ZnNr/WB-test-L0/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRepo keeps orders in memory and tracks soft-deleted ones
+type stubRepo struct {
+	orders   map[string]models.Order
+	deleted  map[string]bool
+	history  map[string][]models.OrderVersion
+	timeline map[string][]models.StatusChange
+}
+
+func newStubRepo(orders ...models.Order) *stubRepo {
+	r := &stubRepo{orders: make(map[string]models.Order), deleted: make(map[string]bool), history: make(map[string][]models.OrderVersion), timeline: make(map[string][]models.StatusChange)}
+	for _, order := range orders {
+		r.orders[order.OrderUID] = order
+	}
+	return r
+}
+
+func (r *stubRepo) AddOrder(ctx context.Context, order models.Order) error {
+	r.orders[order.OrderUID] = order
+	return nil
+}
+
+func (r *stubRepo) UpsertOrder(ctx context.Context, order models.Order) (*models.Order, error) {
+	if r.deleted[order.OrderUID] {
+		return nil, fmt.Errorf("order %s: %w", order.OrderUID, repository.ErrOrderDeleted)
+	}
+	if previous, ok := r.orders[order.OrderUID]; ok {
+		r.history[order.OrderUID] = append([]models.OrderVersion{{Version: previous.Version, Order: previous}}, r.history[order.OrderUID]...)
+	}
+	r.orders[order.OrderUID] = order
+	return &order, nil
+}
+
+func (r *stubRepo) GetOrderHistory(ctx context.Context, orderUID string) ([]models.OrderVersion, error) {
+	if _, ok := r.orders[orderUID]; !ok || r.deleted[orderUID] {
+		return nil, fmt.Errorf("order %s: %w", orderUID, repository.ErrOrderNotFound)
+	}
+	return r.history[orderUID], nil
+}
+
+func (r *stubRepo) ChangeOrderStatus(ctx context.Context, orderUID string, change models.StatusChange) (*models.Order, error) {
+	order, ok := r.orders[orderUID]
+	if !ok || r.deleted[orderUID] {
+		return nil, fmt.Errorf("order %s: %w", orderUID, repository.ErrOrderNotFound)
+	}
+	order.Status = change.Status
+	r.orders[orderUID] = order
+	r.timeline[orderUID] = append(r.timeline[orderUID], change)
+	return &order, nil
+}
+
+func (r *stubRepo) GetOrderTimeline(ctx context.Context, orderUID string) ([]models.StatusChange, error) {
+	if _, ok := r.orders[orderUID]; !ok || r.deleted[orderUID] {
+		return nil, fmt.Errorf("order %s: %w", orderUID, repository.ErrOrderNotFound)
+	}
+	return r.timeline[orderUID], nil
+}
+
+func (r *stubRepo) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	order, ok := r.orders[orderUID]
+	if !ok || r.deleted[orderUID] {
+		return nil, nil
+	}
+	return &order, nil
+}
+
+func (r *stubRepo) GetOrders(context.Context) ([]models.Order, error) { return nil, nil }
+
+func (r *stubRepo) StreamOrders(context.Context, int, func([]models.Order) error) error { return nil }
+
+func (r *stubRepo) FindOrders(context.Context, query.Params) (query.Page, error) {
+	return query.Page{}, nil
+}
+
+func (r *stubRepo) DeleteOrder(ctx context.Context, orderUID string) error {
+	if _, ok := r.orders[orderUID]; !ok {
+		return fmt.Errorf("order %s: %w", orderUID, repository.ErrOrderNotFound)
+	}
+	delete(r.orders, orderUID)
+	delete(r.deleted, orderUID)
+	return nil
+}
+
+func (r *stubRepo) SoftDeleteOrder(ctx context.Context, orderUID string) error {
+	if _, ok := r.orders[orderUID]; !ok || r.deleted[orderUID] {
+		return fmt.Errorf("order %s: %w", orderUID, repository.ErrOrderNotFound)
+	}
+	r.deleted[orderUID] = true
+	return nil
+}
+
+func (r *stubRepo) SoftDeleteOrders(context.Context) (int64, error) {
+	var deleted int64
+	for orderUID := range r.orders {
+		if !r.deleted[orderUID] {
+			r.deleted[orderUID] = true
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *stubRepo) RestoreOrder(ctx context.Context, orderUID string) error {
+	if !r.deleted[orderUID] {
+		return fmt.Errorf("order %s: %w", orderUID, repository.ErrOrderNotFound)
+	}
+	delete(r.deleted, orderUID)
+	return nil
+}
+
+type recordingPublisher struct {
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(event events.Event) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func newTestRouter(repo *stubRepo, publisher events.Publisher) (http.Handler, *cache.Cache) {
+	c := cache.New(10)
+	orders := cache.NewReadThrough(c, repo, time.Minute)
+	return NewController(c, orders, repo, nil, nil, nil, true, nil, publisher).SetupRouter(), c
+}
+
+func do(router http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+// Without auth and without the anonymous opt-in only reads are served
+func TestDisabledAuthRejectsOperatorAndAdminRoutes(t *testing.T) {
+	// Arrange
+	repo := newStubRepo(models.Order{OrderUID: "123"})
+	c := cache.New(10)
+	router := NewController(c, cache.NewReadThrough(c, repo, time.Minute), repo, nil, nil, nil, false, nil, nil).SetupRouter()
+
+	// Act
+	read := do(router, http.MethodGet, "/order/123")
+	deleteOne := do(router, http.MethodDelete, "/order/123")
+	purge := do(router, http.MethodDelete, "/order/123/purge")
+	deleteAll := do(router, http.MethodDelete, "/delorders")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, read.Code)
+	assert.Equal(t, http.StatusForbidden, deleteOne.Code)
+	assert.Equal(t, http.StatusForbidden, purge.Code)
+	assert.Equal(t, http.StatusForbidden, deleteAll.Code)
+	assert.False(t, repo.deleted["123"])
+	assert.Contains(t, repo.orders, "123")
+}
+
+// Deleting an order removes it from the database and the cache and emits an event
+func TestDeleteOrderActsOnRepository(t *testing.T) {
+	// Arrange
+	repo := newStubRepo(models.Order{OrderUID: "123"})
+	publisher := &recordingPublisher{}
+	router, c := newTestRouter(repo, publisher)
+	assert.Equal(t, http.StatusOK, do(router, http.MethodGet, "/order/123").Code)
+	assert.True(t, c.OrderExists("123"))
+
+	// Act
+	rec := do(router, http.MethodDelete, "/order/123")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, repo.deleted["123"])
+	assert.False(t, c.OrderExists("123"))
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodGet, "/order/123").Code)
+	if assert.Len(t, publisher.events, 1) {
+		assert.Equal(t, events.OrderDeleted, publisher.events[0].Type)
+		assert.Equal(t, "123", publisher.events[0].OrderUID)
+		assert.False(t, publisher.events[0].Hard)
+	}
+}
+
+// A restored order is readable again despite the earlier negative lookup
+func TestRestoreOrderMakesOrderVisible(t *testing.T) {
+	// Arrange
+	repo := newStubRepo(models.Order{OrderUID: "123"})
+	router, _ := newTestRouter(repo, nil)
+	do(router, http.MethodDelete, "/order/123")
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodGet, "/order/123").Code)
+
+	// Act
+	rec := do(router, http.MethodPost, "/order/123/restore")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusOK, do(router, http.MethodGet, "/order/123").Code)
+}
+
+// Unknown orders are reported as 404 and produce no events
+func TestDeleteUnknownOrder(t *testing.T) {
+	publisher := &recordingPublisher{}
+	router, _ := newTestRouter(newStubRepo(), publisher)
+
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodDelete, "/order/missing").Code)
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodDelete, "/order/missing/purge").Code)
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodPost, "/order/missing/restore").Code)
+	assert.Empty(t, publisher.events)
+}
+
+// Clearing orders soft-deletes every order and emits an event for each
+func TestClearOrdersDeletesAll(t *testing.T) {
+	repo := newStubRepo(models.Order{OrderUID: "1"}, models.Order{OrderUID: "2"})
+	publisher := &recordingPublisher{}
+	router, c := newTestRouter(repo, publisher)
+	c.SaveOrder(models.Order{OrderUID: "1"})
+
+	assert.Equal(t, http.StatusOK, do(router, http.MethodDelete, "/delorders").Code)
+	assert.True(t, repo.deleted["1"])
+	assert.True(t, repo.deleted["2"])
+	assert.False(t, c.OrderExists("1"))
+	require.Len(t, publisher.events, 1, "one bulk event instead of one per order")
+	assert.Equal(t, events.OrdersDeleted, publisher.events[0].Type)
+	assert.Equal(t, int64(2), publisher.events[0].Count)
+	assert.Empty(t, publisher.events[0].OrderUID)
+}
+
+// History lists replaced versions and is not available for deleted orders
+func TestGetOrderHistory(t *testing.T) {
+	// Arrange
+	repo := newStubRepo(models.Order{OrderUID: "123", Version: 1})
+	_, _ = repo.UpsertOrder(context.Background(), models.Order{OrderUID: "123", Version: 2})
+	router, _ := newTestRouter(repo, nil)
+
+	// Act
+	rec := do(router, http.MethodGet, "/order/123/history")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"version":1`)
+	assert.NotContains(t, rec.Body.String(), `"version":2`)
+
+	do(router, http.MethodDelete, "/order/123")
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodGet, "/order/123/history").Code)
+}
+
+// Timeline lists status changes in order and is not available for unknown or deleted orders
+func TestGetOrderTimeline(t *testing.T) {
+	// Arrange
+	repo := newStubRepo(models.Order{OrderUID: "123", Status: models.StatusCreated})
+	changedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, _ = repo.ChangeOrderStatus(context.Background(), "123", models.StatusChange{Status: models.StatusPaid, ChangedAt: changedAt})
+	_, _ = repo.ChangeOrderStatus(context.Background(), "123", models.StatusChange{Status: models.StatusCancelled, ChangedAt: changedAt.Add(time.Hour), Reason: "changed mind"})
+	router, _ := newTestRouter(repo, nil)
+
+	// Act
+	rec := do(router, http.MethodGet, "/order/123/timeline")
+
+	// Assert
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"status":"paid","changed_at":"2024-01-02T03:04:05Z"},
+		{"status":"cancelled","changed_at":"2024-01-02T04:04:05Z","reason":"changed mind"}
+	]`, rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodGet, "/order/unknown/timeline").Code)
+
+	do(router, http.MethodDelete, "/order/123")
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodGet, "/order/123/timeline").Code)
+}
+
+// Posted orders are validated field by field before they are stored and cached
+func TestPostOrderValidates(t *testing.T) {
+	// Arrange
+	repo := newStubRepo()
+	router, c := newTestRouter(repo, nil)
+	valid, _ := json.Marshal(order_gen.GenerateOrder())
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(body)))
+		return rec
+	}
+
+	// Act
+	invalid := post(`{"order_uid":"123","items":[]}`)
+	malformed := post(`{"order_uid":`)
+	stored := post(string(valid))
+
+	// Assert
+	assert.Equal(t, http.StatusUnprocessableEntity, invalid.Code)
+	assert.Contains(t, invalid.Body.String(), `"field":"items"`)
+	assert.Contains(t, invalid.Body.String(), `"field":"delivery.email"`)
+	assert.NotContains(t, repo.orders, "123")
+	assert.Equal(t, http.StatusBadRequest, malformed.Code)
+	assert.Equal(t, http.StatusOK, stored.Code)
+	assert.Len(t, repo.orders, 1)
+	assert.Len(t, c.GetAllOrders(), 1)
+}
+
+// Posting a new version of a deleted order is a conflict and doesn't bring it back into the cache
+func TestPostOrderRejectsDeletedOrder(t *testing.T) {
+	// Arrange
+	order := order_gen.GenerateOrder()
+	repo := newStubRepo(order)
+	repo.deleted[order.OrderUID] = true
+	router, c := newTestRouter(repo, nil)
+	body, _ := json.Marshal(order)
+
+	// Act
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(string(body))))
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.False(t, c.OrderExists(order.OrderUID))
+	assert.Equal(t, http.StatusNotFound, do(router, http.MethodGet, "/order/"+order.OrderUID).Code)
+}
+
+// Orders can be filtered by a date_created range and sorted by timestamp through the API
+func TestGetAllOrdersByDateRange(t *testing.T) {
+	// Arrange
+	router, c := newTestRouter(newStubRepo(), nil)
+	for uid, created := range map[string]string{"1": "2024-01-01T23:30:00-02:00", "2": "2024-01-02T00:30:00Z", "3": "2024-01-03T00:00:00Z"} {
+		createdAt, _ := models.ParseDate(created)
+		c.SaveOrder(models.Order{OrderUID: uid, DateCreated: createdAt})
+	}
+
+	// Act
+	rec := do(router, http.MethodGet, "/orders?date_from=2024-01-02&date_to=2024-01-02T23:59:59Z&sort=-date_created")
+
+	// Assert
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page query.Page
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	uids := make([]string, len(page.Orders))
+	for i, order := range page.Orders {
+		uids[i] = order.OrderUID
+	}
+	assert.Equal(t, []string{"1", "2"}, uids)
+}
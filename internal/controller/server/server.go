@@ -1,40 +1,70 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/ZnNr/WB-test-L0/internal/auth"
 	"github.com/ZnNr/WB-test-L0/internal/cache"
 	"github.com/ZnNr/WB-test-L0/internal/controller/router"
+	"github.com/ZnNr/WB-test-L0/internal/dlq"
+	"github.com/ZnNr/WB-test-L0/internal/events"
+	"github.com/ZnNr/WB-test-L0/internal/health"
+	"github.com/ZnNr/WB-test-L0/internal/repository"
 	"github.com/ZnNr/WB-test-L0/internal/repository/config"
 	"log"
 	"net/http"
 )
 
 type Server struct {
-	cfg      config.ConfigApp
-	Cache    *cache.Cache
-	HTTPPort string
+	cfg         config.ConfigApp
+	Cache       *cache.Cache
+	Orders      *cache.ReadThrough
+	Repo        repository.Orders
+	DeadLetters *dlq.Queue
+	Health      *health.Checker
+	HTTPPort    string
+
+	httpServer *http.Server
 }
 
-func New(cfgPath string, cache *cache.Cache) (*Server, error) {
-	cfg, err := config.Load(cfgPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
+// New собирает HTTP-сервер по уже загруженной конфигурации
+func New(cfg *config.Config, cache *cache.Cache, orders *cache.ReadThrough, repo repository.Orders, deadLetters *dlq.Queue, checker *health.Checker, authenticator *auth.Authenticator, publisher events.Publisher) *Server {
+	s := &Server{
+		cfg:         cfg.App,
+		Cache:       cache,
+		Orders:      orders,
+		Repo:        repo,
+		DeadLetters: deadLetters,
+		Health:      checker,
+		HTTPPort:    fmt.Sprintf("%s:%s", cfg.App.Host, cfg.App.Port),
 	}
-
-	return &Server{
-		cfg:      cfg.App,
-		Cache:    cache,
-		HTTPPort: fmt.Sprintf("%s:%s", cfg.App.Host, cfg.App.Port),
-	}, nil
+	s.httpServer = &http.Server{
+		Addr:         s.HTTPPort,
+		Handler:      router.NewController(cache, orders, repo, deadLetters, checker, authenticator, cfg.Auth.InsecureAllowAnonymous, cfg.App.CORSAllowedOrigins, publisher).SetupRouter(),
+		ReadTimeout:  cfg.App.ReadTimeout,
+		WriteTimeout: cfg.App.WriteTimeout,
+		IdleTimeout:  cfg.App.IdleTimeout,
+	}
+	return s
 }
 
+// Launch обслуживает запросы до вызова Shutdown
 func (s *Server) Launch() error {
-	r := router.NewController(s.Cache).SetupRouter()
 	log.Printf("Starting server at %s\n", s.HTTPPort)
 
-	err := http.ListenAndServe(s.HTTPPort, r)
-	if err != nil {
+	err := s.httpServer.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("failed to launch server: %w", err)
 	}
 	return nil
 }
+
+// Shutdown перестаёт принимать соединения и ждёт завершения текущих запросов,
+// но не дольше, чем позволяет ctx
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/broker"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/repository"
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+)
+
+// Заголовки, которыми помечается сообщение в dead-letter топике
+const (
+	HeaderStage             = "dlq-stage"
+	HeaderError             = "dlq-error"
+	HeaderOriginalTopic     = "dlq-original-topic"
+	HeaderOriginalPartition = "dlq-original-partition"
+	HeaderOriginalOffset    = "dlq-original-offset"
+	HeaderAttempts          = "dlq-attempts"
+	HeaderFailedAt          = "dlq-failed-at"
+	HeaderRedrivenFrom      = "dlq-redriven-from"
+
+	// headerPrefix общий префикс служебных заголовков карантина
+	headerPrefix = "dlq-"
+)
+
+// ErrNotFound возвращается, если сообщения с таким ID нет в карантине
+var ErrNotFound = errors.New("dead letter not found")
+
+// Queue публикует необработанные сообщения в dead-letter топик, сохраняет их
+// в карантин и умеет отправлять их обратно в основной топик.
+type Queue struct {
+	publisher   broker.Publisher
+	repo        repository.DeadLetters
+	topic       string
+	sourceTopic string
+}
+
+// New создаёт очередь, публикующую в dead-letter топик из настроек через publisher.
+func New(cfg config.KafkaConfig, publisher broker.Publisher, repo repository.DeadLetters) *Queue {
+	return &Queue{
+		publisher:   publisher,
+		repo:        repo,
+		topic:       cfg.DLQTopic,
+		sourceTopic: cfg.Topic,
+	}
+}
+
+// Send помещает сообщение в dead-letter топик и в карантин.
+// Повторный вызов для того же сообщения не создаёт в карантине новую строку.
+func (q *Queue) Send(ctx context.Context, msg *broker.Message, failure *Failure, attempts int) (*models.DeadLetter, error) {
+	letter := &models.DeadLetter{
+		Stage:     string(failure.Stage),
+		Error:     failure.Err.Error(),
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Attempts:  attempts,
+		Key:       string(msg.Key),
+		Payload:   string(msg.Value),
+		Headers:   originalHeaders(msg.Headers),
+	}
+
+	if err := q.repo.AddDeadLetter(ctx, letter); err != nil {
+		return nil, err
+	}
+
+	err := q.publisher.Publish(ctx, &broker.Message{
+		Topic:   q.topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: append(letterHeaders(letter), failureHeaders(letter)...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish dead letter: %w", err)
+	}
+	return letter, nil
+}
+
+// List возвращает страницу сообщений из карантина.
+func (q *Queue) List(ctx context.Context, limit, offset int) ([]models.DeadLetter, error) {
+	return q.repo.GetDeadLetters(ctx, limit, offset)
+}
+
+// Get возвращает сообщение из карантина по ID.
+func (q *Queue) Get(ctx context.Context, id int64) (*models.DeadLetter, error) {
+	letter, err := q.repo.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if letter == nil {
+		return nil, ErrNotFound
+	}
+	return letter, nil
+}
+
+// Redrive отправляет сообщение из карантина обратно в основной топик с исходными заголовками.
+// Счётчик попыток передаётся в заголовке, чтобы консьюмер продолжил отсчёт.
+func (q *Queue) Redrive(ctx context.Context, id int64) (*models.DeadLetter, error) {
+	letter, err := q.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &broker.Message{
+		Topic: q.sourceTopic,
+		Key:   []byte(letter.Key),
+		Value: []byte(letter.Payload),
+		Headers: append(letterHeaders(letter),
+			broker.Header{Key: HeaderAttempts, Value: strconv.Itoa(letter.Attempts)},
+			broker.Header{Key: HeaderRedrivenFrom, Value: strconv.FormatInt(letter.ID, 10)},
+		),
+	}
+
+	if err := q.publisher.Publish(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to redrive dead letter %d: %w", id, err)
+	}
+
+	if err := q.repo.MarkRedriven(ctx, id); err != nil {
+		return nil, err
+	}
+	return q.Get(ctx, id)
+}
+
+// Attempts возвращает число попыток обработки, уже сделанных до повторной отправки сообщения.
+func Attempts(msg *broker.Message) int {
+	value, ok := msg.Header(HeaderAttempts)
+	if !ok {
+		return 0
+	}
+	attempts, err := strconv.Atoi(value)
+	if err != nil || attempts < 0 {
+		return 0
+	}
+	return attempts
+}
+
+// originalHeaders возвращает заголовки сообщения без служебных заголовков карантина,
+// оставшихся от прошлых попыток; из повторяющихся заголовков остаётся последний
+func originalHeaders(headers []broker.Header) map[string]string {
+	var original map[string]string
+	for _, header := range headers {
+		if strings.HasPrefix(header.Key, headerPrefix) {
+			continue
+		}
+		if original == nil {
+			original = make(map[string]string)
+		}
+		original[header.Key] = header.Value
+	}
+	return original
+}
+
+// letterHeaders возвращает сохранённые заголовки исходного сообщения в порядке ключей
+func letterHeaders(letter *models.DeadLetter) []broker.Header {
+	keys := make([]string, 0, len(letter.Headers))
+	for key := range letter.Headers {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	headers := make([]broker.Header, 0, len(keys))
+	for _, key := range keys {
+		headers = append(headers, broker.Header{Key: key, Value: letter.Headers[key]})
+	}
+	return headers
+}
+
+func failureHeaders(letter *models.DeadLetter) []broker.Header {
+	return []broker.Header{
+		{Key: HeaderStage, Value: letter.Stage},
+		{Key: HeaderError, Value: letter.Error},
+		{Key: HeaderOriginalTopic, Value: letter.Topic},
+		{Key: HeaderOriginalPartition, Value: strconv.FormatInt(int64(letter.Partition), 10)},
+		{Key: HeaderOriginalOffset, Value: strconv.FormatInt(letter.Offset, 10)},
+		{Key: HeaderAttempts, Value: strconv.Itoa(letter.Attempts)},
+		{Key: HeaderFailedAt, Value: letter.FailedAt.UTC().Format(time.RFC3339)},
+	}
+}
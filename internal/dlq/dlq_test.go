@@ -0,0 +1,33 @@
+package dlq
+
+import (
+	"testing"
+
+	"github.com/ZnNr/WB-test-L0/internal/broker"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Keeps the original message headers and drops quarantine headers left from earlier attempts
+func TestOriginalHeadersDropsQuarantineHeaders(t *testing.T) {
+	headers := []broker.Header{
+		{Key: "ce_type", Value: "order.created"},
+		{Key: HeaderAttempts, Value: "3"},
+		{Key: HeaderRedrivenFrom, Value: "7"},
+		{Key: "content-type", Value: "application/json"},
+	}
+
+	assert.Equal(t, map[string]string{"ce_type": "order.created", "content-type": "application/json"}, originalHeaders(headers))
+	assert.Nil(t, originalHeaders([]broker.Header{{Key: HeaderStage, Value: "store"}}))
+}
+
+// Restores stored headers in key order so redriven messages are reproducible
+func TestLetterHeadersAreSortedByKey(t *testing.T) {
+	letter := &models.DeadLetter{Headers: map[string]string{"content-type": "application/json", "ce_type": "order.created", "ce_id": "1"}}
+
+	assert.Equal(t, []broker.Header{
+		{Key: "ce_id", Value: "1"},
+		{Key: "ce_type", Value: "order.created"},
+		{Key: "content-type", Value: "application/json"},
+	}, letterHeaders(letter))
+}
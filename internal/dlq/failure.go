@@ -0,0 +1,48 @@
+package dlq
+
+import "errors"
+
+// Stage этап обработки сообщения, на котором произошла ошибка
+type Stage string
+
+const (
+	// StageDecode сообщение не удалось разобрать; повторять обработку бессмысленно
+	StageDecode Stage = "decode"
+	// StageValidate заказ не прошёл проверку; повторять обработку бессмысленно
+	StageValidate Stage = "validate"
+	// StageStore заказ не удалось сохранить; обработку можно повторить
+	StageStore Stage = "store"
+)
+
+// Failure ошибка обработки сообщения с указанием этапа
+type Failure struct {
+	Stage Stage
+	Err   error
+}
+
+// Fail оборачивает ошибку этапом обработки
+func Fail(stage Stage, err error) error {
+	return &Failure{Stage: stage, Err: err}
+}
+
+func (f *Failure) Error() string {
+	return string(f.Stage) + ": " + f.Err.Error()
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// Retryable сообщает, имеет ли смысл повторять обработку
+func (f *Failure) Retryable() bool {
+	return f.Stage != StageDecode && f.Stage != StageValidate
+}
+
+// AsFailure извлекает Failure из цепочки ошибок; ошибки без этапа считаются ошибками сохранения
+func AsFailure(err error) *Failure {
+	var failure *Failure
+	if errors.As(err, &failure) {
+		return failure
+	}
+	return &Failure{Stage: StageStore, Err: err}
+}
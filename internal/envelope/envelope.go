@@ -0,0 +1,295 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/broker"
+	"github.com/google/uuid"
+)
+
+// Типы событий о заказе
+const (
+	TypeOrderCreated       = "order.created"
+	TypeOrderUpdated       = "order.updated"
+	TypeOrderStatusChanged = "order.status_changed"
+	TypeOrderCancelled     = "order.cancelled"
+	TypeOrderDeleted       = "order.deleted"
+)
+
+const (
+	// SpecVersion поддерживаемая версия CloudEvents
+	SpecVersion = "1.0"
+	// SchemaVersion текущая версия схемы data; события с версией новее не обрабатываются
+	SchemaVersion = 1
+	// Source источник событий, которые публикует сервис
+	Source = "/orders-service"
+
+	ContentTypeJSON            = "application/json"
+	ContentTypeCloudEventsJSON = "application/cloudevents+json"
+
+	// HeaderContentType заголовок Kafka с типом содержимого data или всего события
+	HeaderContentType = "content-type"
+	// HeaderPrefix префикс заголовков с атрибутами события в binary-режиме
+	HeaderPrefix = "ce_"
+)
+
+// Mode способ, которым событие передано в сообщении
+type Mode string
+
+const (
+	// ModeBinary атрибуты в заголовках ce_*, значение сообщения — data
+	ModeBinary Mode = "binary"
+	// ModeStructured значение сообщения — событие CloudEvents в JSON
+	ModeStructured Mode = "structured"
+	// ModeLegacy значение сообщения — заказ или конверт {"type": ...} без атрибутов CloudEvents
+	ModeLegacy Mode = "legacy"
+)
+
+// ErrInvalid событие не соответствует CloudEvents или версии схемы
+var ErrInvalid = errors.New("invalid event")
+
+// Envelope событие CloudEvents 1.0. SchemaVersion и PartitionKey — расширения:
+// версия схемы data и ключ сообщения Kafka.
+type Envelope struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            *time.Time      `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	SchemaVersion   int             `json:"schemaversion,omitempty"`
+	PartitionKey    string          `json:"partitionkey,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	// DataBase64 data в base64 для structured-режима; при разборе переносится в Data
+	DataBase64 []byte `json:"data_base64,omitempty"`
+
+	Mode Mode `json:"-"`
+}
+
+// New создаёт событие eventType о заказе subject с данными data в JSON
+func New(eventType, subject string, data any) (*Envelope, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+	now := time.Now().UTC()
+	return &Envelope{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.New().String(),
+		Source:          Source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            &now,
+		DataContentType: ContentTypeJSON,
+		SchemaVersion:   SchemaVersion,
+		PartitionKey:    subject,
+		Data:            payload,
+	}, nil
+}
+
+// Binary переводит событие в сообщение binary-режима: атрибуты уходят в заголовки,
+// а значением остаётся data, поэтому консьюмеры без поддержки CloudEvents читают его как раньше
+func (e *Envelope) Binary(topic string) *broker.Message {
+	attributes := []struct{ name, value string }{
+		{"specversion", e.SpecVersion},
+		{"id", e.ID},
+		{"source", e.Source},
+		{"type", e.Type},
+		{"subject", e.Subject},
+		{"dataschema", e.DataSchema},
+		{"partitionkey", e.PartitionKey},
+	}
+	if e.Time != nil {
+		attributes = append(attributes, struct{ name, value string }{"time", e.Time.Format(time.RFC3339Nano)})
+	}
+	if e.SchemaVersion != 0 {
+		attributes = append(attributes, struct{ name, value string }{"schemaversion", strconv.Itoa(e.SchemaVersion)})
+	}
+
+	msg := &broker.Message{Topic: topic, Key: []byte(e.PartitionKey), Value: e.Data}
+	for _, attribute := range attributes {
+		if attribute.value != "" {
+			msg.Headers = append(msg.Headers, broker.Header{Key: HeaderPrefix + attribute.name, Value: attribute.value})
+		}
+	}
+	if e.DataContentType != "" {
+		msg.Headers = append(msg.Headers, broker.Header{Key: HeaderContentType, Value: e.DataContentType})
+	}
+	return msg
+}
+
+// Structured переводит событие в сообщение structured-режима: значение — событие целиком в JSON
+func (e *Envelope) Structured(topic string) (*broker.Message, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return &broker.Message{
+		Topic:   topic,
+		Key:     []byte(e.PartitionKey),
+		Value:   payload,
+		Headers: []broker.Header{{Key: HeaderContentType, Value: ContentTypeCloudEventsJSON}},
+	}, nil
+}
+
+// Decode разбирает сообщение в событие. Сообщение с заголовком ce_specversion читается в binary-режиме,
+// JSON с полем specversion или с типом содержимого application/cloudevents+json — в structured-режиме.
+// Прежние форматы тоже поддерживаются: конверт {"type": ...} становится событием этого типа,
+// а заказ без конверта — событием order.created.
+// Ошибка разбора JSON возвращается как есть, несоответствие CloudEvents — с ErrInvalid.
+func Decode(msg *broker.Message) (*Envelope, error) {
+	var (
+		e   *Envelope
+		err error
+	)
+	contentType, _ := msg.Header(HeaderContentType)
+	if _, ok := msg.Header(HeaderPrefix + "specversion"); ok {
+		e, err = decodeBinary(msg)
+	} else if mediaType(contentType) == ContentTypeCloudEventsJSON {
+		e, err = decodeStructured(msg.Value)
+	} else {
+		e, err = decodeJSON(msg.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if e.PartitionKey == "" {
+		e.PartitionKey = string(msg.Key)
+	}
+	if e.SchemaVersion == 0 {
+		e.SchemaVersion = SchemaVersion
+	}
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// decodeJSON разбирает значение без заголовков CloudEvents
+func decodeJSON(value []byte) (*Envelope, error) {
+	var probe struct {
+		SpecVersion *string `json:"specversion"`
+		Type        string  `json:"type"`
+	}
+	if err := json.Unmarshal(value, &probe); err != nil {
+		return nil, err
+	}
+	if probe.SpecVersion != nil {
+		return decodeStructured(value)
+	}
+
+	e := &Envelope{SpecVersion: SpecVersion, Type: probe.Type, Data: value, Mode: ModeLegacy}
+	if e.Type == "" {
+		e.Type = TypeOrderCreated
+	}
+	return e, nil
+}
+
+func decodeStructured(value []byte) (*Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(value, &e); err != nil {
+		return nil, err
+	}
+	if len(e.DataBase64) > 0 {
+		if len(e.Data) > 0 {
+			return nil, fmt.Errorf("%w: both data and data_base64 are set", ErrInvalid)
+		}
+		e.Data, e.DataBase64 = e.DataBase64, nil
+	}
+	e.Mode = ModeStructured
+	return &e, nil
+}
+
+func decodeBinary(msg *broker.Message) (*Envelope, error) {
+	attribute := func(name string) string {
+		value, _ := msg.Header(HeaderPrefix + name)
+		return value
+	}
+
+	e := &Envelope{
+		SpecVersion:  attribute("specversion"),
+		ID:           attribute("id"),
+		Source:       attribute("source"),
+		Type:         attribute("type"),
+		Subject:      attribute("subject"),
+		DataSchema:   attribute("dataschema"),
+		PartitionKey: attribute("partitionkey"),
+		Data:         msg.Value,
+		Mode:         ModeBinary,
+	}
+	e.DataContentType, _ = msg.Header(HeaderContentType)
+
+	if value := attribute("time"); value != "" {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: time: %q is not an RFC 3339 timestamp", ErrInvalid, value)
+		}
+		e.Time = &t
+	}
+	if value := attribute("schemaversion"); value != "" {
+		version, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: schemaversion: %q is not a number", ErrInvalid, value)
+		}
+		e.SchemaVersion = version
+	}
+	return e, nil
+}
+
+// validate проверяет обязательные атрибуты CloudEvents; у событий прежнего формата их нет
+func (e *Envelope) validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(e.SpecVersion == SpecVersion, "specversion: %q is not supported", e.SpecVersion)
+	if e.Mode != ModeLegacy {
+		check(e.ID != "", "id: is required")
+		check(e.Source != "", "source: is required")
+	}
+	check(e.Type != "", "type: is required")
+	check(e.SchemaVersion >= 1 && e.SchemaVersion <= SchemaVersion,
+		"schemaversion: %d is not supported, expected 1..%d", e.SchemaVersion, SchemaVersion)
+	if e.DataContentType != "" {
+		check(isJSON(e.DataContentType), "datacontenttype: %q is not JSON", e.DataContentType)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// OrderUID возвращает заказ, к которому относится событие: subject, а без него — ключ сообщения
+func (e *Envelope) OrderUID() string {
+	if e.Subject != "" {
+		return e.Subject
+	}
+	return e.PartitionKey
+}
+
+// isJSON проверяет тип содержимого: application/json или любой тип с суффиксом +json
+func isJSON(contentType string) bool {
+	media := mediaType(contentType)
+	return media == ContentTypeJSON || strings.HasSuffix(media, "+json")
+}
+
+func mediaType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return media
+}
@@ -0,0 +1,150 @@
+package envelope
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEnvelope(t *testing.T) *Envelope {
+	t.Helper()
+	e, err := New(TypeOrderUpdated, "b563feb7b2b84b6test", map[string]string{"order_uid": "b563feb7b2b84b6test"})
+	require.NoError(t, err)
+	return e
+}
+
+// Binary mode keeps attributes in ce_ headers and the bare data as the value
+func TestBinaryRoundTrip(t *testing.T) {
+	// Arrange
+	e := newTestEnvelope(t)
+
+	// Act
+	msg := e.Binary("orders")
+	decoded, err := Decode(msg)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "b563feb7b2b84b6test", string(msg.Key))
+	assert.JSONEq(t, `{"order_uid":"b563feb7b2b84b6test"}`, string(msg.Value))
+	typ, _ := msg.Header("ce_type")
+	assert.Equal(t, TypeOrderUpdated, typ)
+	assert.Equal(t, ModeBinary, decoded.Mode)
+	assert.Equal(t, e.ID, decoded.ID)
+	assert.Equal(t, e.Type, decoded.Type)
+	assert.Equal(t, e.Subject, decoded.Subject)
+	assert.Equal(t, ContentTypeJSON, decoded.DataContentType)
+	assert.Equal(t, SchemaVersion, decoded.SchemaVersion)
+	assert.True(t, e.Time.Equal(*decoded.Time))
+}
+
+// Structured mode carries the whole event as JSON
+func TestStructuredRoundTrip(t *testing.T) {
+	// Arrange
+	e := newTestEnvelope(t)
+
+	// Act
+	msg, err := e.Structured("orders")
+	require.NoError(t, err)
+	decoded, err := Decode(msg)
+
+	// Assert
+	require.NoError(t, err)
+	contentType, _ := msg.Header(HeaderContentType)
+	assert.Equal(t, ContentTypeCloudEventsJSON, contentType)
+	assert.Equal(t, ModeStructured, decoded.Mode)
+	assert.Equal(t, e.ID, decoded.ID)
+	assert.Equal(t, e.PartitionKey, decoded.PartitionKey)
+	assert.JSONEq(t, string(e.Data), string(decoded.Data))
+}
+
+// A structured event is recognised by its specversion even without a content-type header
+func TestDecodeStructuredWithoutContentType(t *testing.T) {
+	data := base64.StdEncoding.EncodeToString([]byte(`{"order_uid":"1"}`))
+	msg := &broker.Message{
+		Key:   []byte("1"),
+		Value: []byte(`{"specversion":"1.0","id":"42","source":"/checkout","type":"order.cancelled","data_base64":"` + data + `"}`),
+	}
+
+	e, err := Decode(msg)
+
+	require.NoError(t, err)
+	assert.Equal(t, ModeStructured, e.Mode)
+	assert.Equal(t, TypeOrderCancelled, e.Type)
+	assert.Equal(t, 1, e.SchemaVersion)
+	assert.Equal(t, "1", e.OrderUID())
+	assert.JSONEq(t, `{"order_uid":"1"}`, string(e.Data))
+}
+
+// Bare orders and typed envelopes without CloudEvents attributes keep working
+func TestDecodeLegacyFormats(t *testing.T) {
+	order, err := Decode(&broker.Message{Value: []byte(`{"order_uid":"1"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, ModeLegacy, order.Mode)
+	assert.Equal(t, TypeOrderCreated, order.Type)
+	assert.Equal(t, SchemaVersion, order.SchemaVersion)
+	assert.JSONEq(t, `{"order_uid":"1"}`, string(order.Data))
+
+	deleted, err := Decode(&broker.Message{Key: []byte("1"), Value: []byte(`{"type":"order.deleted","hard":true}`)})
+	require.NoError(t, err)
+	assert.Equal(t, TypeOrderDeleted, deleted.Type)
+	assert.Equal(t, "1", deleted.OrderUID())
+}
+
+// Reports events that break CloudEvents or come from a newer schema
+func TestDecodeRejectsInvalidEvents(t *testing.T) {
+	tests := map[string]*broker.Message{
+		"spec version": {Value: []byte(`{"specversion":"0.3","id":"1","source":"/s","type":"order.created"}`)},
+		"missing id":   {Value: []byte(`{"specversion":"1.0","source":"/s","type":"order.created"}`)},
+		"schema":       {Value: []byte(`{"specversion":"1.0","id":"1","source":"/s","type":"order.created","schemaversion":2}`)},
+		"content type": {Value: []byte(`{"specversion":"1.0","id":"1","source":"/s","type":"order.created","datacontenttype":"text/plain","data":"x"}`)},
+		"both data":    {Value: []byte(`{"specversion":"1.0","id":"1","source":"/s","type":"order.created","data":{},"data_base64":"e30="}`)},
+		"binary time": {
+			Value:   []byte(`{}`),
+			Headers: []broker.Header{{Key: "ce_specversion", Value: "1.0"}, {Key: "ce_id", Value: "1"}, {Key: "ce_source", Value: "/s"}, {Key: "ce_type", Value: "order.created"}, {Key: "ce_time", Value: "yesterday"}},
+		},
+		"binary schema": {
+			Value:   []byte(`{}`),
+			Headers: []broker.Header{{Key: "ce_specversion", Value: "1.0"}, {Key: "ce_id", Value: "1"}, {Key: "ce_source", Value: "/s"}, {Key: "ce_type", Value: "order.created"}, {Key: "ce_schemaversion", Value: "v1"}},
+		},
+	}
+
+	for name, msg := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Decode(msg)
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+// Malformed JSON is reported as a decode error rather than an invalid event
+func TestDecodeMalformedJSON(t *testing.T) {
+	_, err := Decode(&broker.Message{Value: []byte("not json")})
+
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalid)
+}
+
+// Accepts JSON content types with parameters and +json suffixes
+func TestDecodeAcceptsJSONContentTypes(t *testing.T) {
+	for _, contentType := range []string{"application/json; charset=utf-8", "application/vnd.orders+json"} {
+		msg := &broker.Message{
+			Value: []byte(`{"order_uid":"1"}`),
+			Headers: []broker.Header{
+				{Key: "ce_specversion", Value: "1.0"},
+				{Key: "ce_id", Value: "1"},
+				{Key: "ce_source", Value: "/s"},
+				{Key: "ce_type", Value: "order.created"},
+				{Key: "ce_time", Value: time.Now().Format(time.RFC3339)},
+				{Key: "content-type", Value: contentType},
+			},
+		}
+
+		_, err := Decode(msg)
+
+		assert.NoError(t, err, contentType)
+	}
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/broker"
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+	"go.uber.org/zap"
+)
+
+// Type тип события об изменении заказа
+type Type string
+
+const (
+	OrderDeleted  Type = "order.deleted"
+	OrderRestored Type = "order.restored"
+	// OrdersDeleted все заказы помечены удалёнными разом; OrderUID не заполняется, число заказов — в Count
+	OrdersDeleted Type = "orders.deleted"
+)
+
+// Event событие об изменении заказа
+type Event struct {
+	Type     Type   `json:"type"`
+	OrderUID string `json:"order_uid,omitempty"`
+	// Count число затронутых заказов для событий обо всех заказах
+	Count int64 `json:"count,omitempty"`
+	// Hard заказ удалён безвозвратно, а не помечен удалённым
+	Hard bool `json:"hard,omitempty"`
+	// Actor кто инициировал изменение
+	Actor      string    `json:"actor,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Publisher отправляет события подписчикам
+type Publisher interface {
+	Publish(event Event) error
+}
+
+// BrokerPublisher публикует события в топик событий; ключ сообщения — order_uid,
+// поэтому события одного заказа попадают в одну партицию и читаются по порядку
+type BrokerPublisher struct {
+	publisher broker.Publisher
+	topic     string
+	logger    *zap.Logger
+}
+
+// NewBrokerPublisher создаёт издателя событий в топик cfg.EventsTopic
+func NewBrokerPublisher(cfg config.KafkaConfig, publisher broker.Publisher, logger *zap.Logger) *BrokerPublisher {
+	return &BrokerPublisher{publisher: publisher, topic: cfg.EventsTopic, logger: logger}
+}
+
+// Publish отправляет событие. Ошибка дополнительно пишется в журнал,
+// чтобы потерянное событие можно было восстановить по логам
+func (p *BrokerPublisher) Publish(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	err = p.publisher.Publish(context.Background(), &broker.Message{
+		Topic: p.topic,
+		Key:   []byte(event.OrderUID),
+		Value: payload,
+	})
+	if err != nil {
+		p.logger.Error("Failed to publish event",
+			zap.Error(err),
+			zap.String("type", string(event.Type)),
+			zap.String("order_uid", event.OrderUID),
+			zap.ByteString("event", payload),
+		)
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc проверяет доступность зависимости; nil означает, что зависимость готова
+type CheckFunc func(ctx context.Context) error
+
+// Result результат проверки одной зависимости
+type Result struct {
+	Status      string     `json:"status"`
+	LatencyMs   float64    `json:"latency_ms"`
+	Error       string     `json:"error,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Report сводный результат проверки готовности
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker выполняет зарегистрированные проверки и помнит последнюю ошибку каждой из них
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.Mutex
+	checks map[string]*check
+}
+
+type check struct {
+	fn          CheckFunc
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// New создаёт Checker; каждая проверка ограничена timeout
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: make(map[string]*check)}
+}
+
+// Register добавляет проверку зависимости
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = &check{fn: fn}
+}
+
+// Check параллельно выполняет все проверки
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	sort.Strings(names)
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, name)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, name string) Result {
+	c.mu.Lock()
+	chk := c.checks[name]
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := chk.fn(ctx)
+	latency := time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := Result{Status: StatusUp, LatencyMs: float64(latency.Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+		chk.lastError = err.Error()
+		chk.lastErrorAt = time.Now()
+	}
+	if chk.lastError != "" {
+		lastErrorAt := chk.lastErrorAt
+		result.LastError = chk.lastError
+		result.LastErrorAt = &lastErrorAt
+	}
+	return result
+}
+
+// LivenessHandler отвечает 200, пока процесс способен обслуживать запросы
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": StatusUp})
+}
+
+// ReadinessHandler отвечает 200, если все зависимости готовы, и 503 в противном случае
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// Flag отмечает завершение этапа запуска, например прогрева кэша
+type Flag struct {
+	mu   sync.RWMutex
+	done bool
+	err  error
+}
+
+// Done отмечает этап завершённым; ненулевая ошибка означает, что этап завершился неудачно
+func (f *Flag) Done(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.done = true
+	f.err = err
+}
+
+// Check реализует CheckFunc
+func (f *Flag) Check(ctx context.Context) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.done {
+		return errors.New("not completed yet")
+	}
+	return f.err
+}
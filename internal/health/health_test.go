@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Readiness fails with 503 while any dependency is down
+func TestReadinessReportsFailingDependency(t *testing.T) {
+	// Arrange
+	checker := New(time.Second)
+	checker.Register("postgres", func(ctx context.Context) error { return nil })
+	checker.Register("kafka_consumer", func(ctx context.Context) error { return errors.New("rebalancing") })
+
+	// Act
+	rec := httptest.NewRecorder()
+	checker.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"kafka_consumer":{"status":"down"`)
+	assert.Contains(t, rec.Body.String(), `"postgres":{"status":"up"`)
+}
+
+// The last error is kept after a dependency recovers
+func TestCheckKeepsLastError(t *testing.T) {
+	// Arrange
+	checker := New(time.Second)
+	fail := true
+	checker.Register("postgres", func(ctx context.Context) error {
+		if fail {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	// Act
+	checker.Check(context.Background())
+	fail = false
+	report := checker.Check(context.Background())
+
+	// Assert
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Equal(t, "connection refused", report.Checks["postgres"].LastError)
+	assert.NotNil(t, report.Checks["postgres"].LastErrorAt)
+}
+
+// A check that outlives the timeout is reported as down
+func TestCheckAppliesTimeout(t *testing.T) {
+	// Arrange
+	checker := New(10 * time.Millisecond)
+	checker.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	// Act
+	report := checker.Check(context.Background())
+
+	// Assert
+	assert.Equal(t, StatusDown, report.Status)
+}
+
+// Flag is not ready until Done is called
+func TestFlagCheck(t *testing.T) {
+	var flag Flag
+	assert.Error(t, flag.Check(context.Background()))
+
+	flag.Done(nil)
+	assert.NoError(t, flag.Check(context.Background()))
+}
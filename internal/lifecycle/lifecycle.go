@@ -0,0 +1,92 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StopFunc останавливает компонент; ctx ограничивает время остановки
+type StopFunc func(ctx context.Context) error
+
+type stage struct {
+	name string
+	stop StopFunc
+}
+
+// Manager останавливает компоненты сервиса в порядке их регистрации
+type Manager struct {
+	timeout time.Duration
+	logger  *zap.Logger
+
+	mu     sync.Mutex
+	stages []stage
+}
+
+// New создаёт Manager; каждый этап остановки ограничен timeout
+func New(timeout time.Duration, logger *zap.Logger) *Manager {
+	return &Manager{timeout: timeout, logger: logger}
+}
+
+// OnShutdown добавляет этап остановки. Этапы выполняются в порядке добавления,
+// поэтому первыми регистрируются компоненты, которые пользуются остальными
+func (m *Manager) OnShutdown(name string, stop StopFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stages = append(m.stages, stage{name: name, stop: stop})
+}
+
+// WaitForSignal блокируется до получения SIGINT или SIGTERM
+func (m *Manager) WaitForSignal() os.Signal {
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigchan)
+	return <-sigchan
+}
+
+// Shutdown выполняет все этапы по очереди. Ошибка или таймаут этапа не прерывает
+// остановку остальных; все ошибки возвращаются вместе
+func (m *Manager) Shutdown() error {
+	m.mu.Lock()
+	stages := append([]stage(nil), m.stages...)
+	m.mu.Unlock()
+
+	var errs []error
+	for _, s := range stages {
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		err := s.stop(ctx)
+		cancel()
+
+		if err != nil {
+			m.logger.Error("Shutdown stage failed", zap.String("stage", s.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		m.logger.Info("Shutdown stage completed", zap.String("stage", s.name), zap.Duration("took", time.Since(start)))
+	}
+	return errors.Join(errs...)
+}
+
+// Wait ждёт wg, но не дольше, чем позволяет ctx
+func Wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,70 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// Stages run in registration order and a failing stage does not stop the rest
+func TestShutdownRunsStagesInOrder(t *testing.T) {
+	// Arrange
+	m := New(time.Second, zap.NewNop())
+	var order []string
+	m.OnShutdown("consumer", func(ctx context.Context) error {
+		order = append(order, "consumer")
+		return errors.New("commit failed")
+	})
+	m.OnShutdown("http", func(ctx context.Context) error {
+		order = append(order, "http")
+		return nil
+	})
+	m.OnShutdown("db", func(ctx context.Context) error {
+		order = append(order, "db")
+		return nil
+	})
+
+	// Act
+	err := m.Shutdown()
+
+	// Assert
+	assert.Equal(t, []string{"consumer", "http", "db"}, order)
+	assert.ErrorContains(t, err, "consumer: commit failed")
+}
+
+// Each stage gets its own deadline
+func TestShutdownBoundsStageWithTimeout(t *testing.T) {
+	// Arrange
+	m := New(20*time.Millisecond, zap.NewNop())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer wg.Done()
+	m.OnShutdown("stuck", func(ctx context.Context) error { return Wait(ctx, &wg) })
+
+	var nextCalled bool
+	m.OnShutdown("next", func(ctx context.Context) error {
+		nextCalled = true
+		return ctx.Err()
+	})
+
+	// Act
+	err := m.Shutdown()
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, nextCalled)
+}
+
+// Wait returns once the group is done
+func TestWaitReturnsWhenGroupDone(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go wg.Done()
+
+	assert.NoError(t, Wait(context.Background(), &wg))
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Default реестр метрик сервиса, отдаваемый по /metrics
+var Default = NewRegistry()
+
+// Результаты обработки сообщения консьюмером
+const (
+	ResultConsumed = "consumed"
+	ResultFailed   = "failed"
+	ResultSkipped  = "skipped"
+	ResultDeleted  = "deleted"
+)
+
+var (
+	ConsumerMessages = Default.NewCounterVec("orders_consumer_messages_total",
+		"Messages processed by the Kafka consumer by result.", "result")
+	ConsumerProcessingSeconds = Default.NewHistogramVec("orders_consumer_processing_seconds",
+		"Time spent processing a Kafka message.", DefBuckets, "result")
+	ConsumerLag = Default.NewGaugeVec("orders_consumer_lag",
+		"Messages between the last processed offset and the partition high water mark.", "topic", "partition")
+
+	DBQuerySeconds = Default.NewHistogramVec("orders_db_query_duration_seconds",
+		"Duration of repository operations.", DefBuckets, "function")
+
+	HTTPRequests = Default.NewCounterVec("orders_http_requests_total",
+		"HTTP requests by route, method and status code.", "route", "method", "code")
+	HTTPRequestSeconds = Default.NewHistogramVec("orders_http_request_duration_seconds",
+		"HTTP request latency by route and method.", DefBuckets, "route", "method")
+)
+
+// ObserveDBQuery записывает длительность операции репозитория; вызывается через defer
+func ObserveDBQuery(function string, start time.Time) {
+	DBQuerySeconds.WithLabelValues(function).ObserveSince(start)
+}
+
+// CacheStats источник показателей кэша
+type CacheStats struct {
+	Entries   func() float64
+	Bytes     func() float64
+	Hits      func() float64
+	Misses    func() float64
+	Evictions func() float64
+}
+
+// RegisterCache регистрирует показатели кэша, которые считываются при каждом сборе метрик
+func RegisterCache(stats CacheStats) {
+	Default.NewGaugeFunc("orders_cache_entries", "Orders currently held in the cache.", stats.Entries)
+	Default.NewGaugeFunc("orders_cache_bytes", "Estimated memory used by cached orders.", stats.Bytes)
+	Default.NewCounterFunc("orders_cache_hits_total", "Cache lookups that found the order.", stats.Hits)
+	Default.NewCounterFunc("orders_cache_misses_total", "Cache lookups that did not find the order.", stats.Misses)
+	Default.NewCounterFunc("orders_cache_evictions_total", "Orders evicted from the cache.", stats.Evictions)
+}
+
+// HTTPMiddleware считает запросы и их длительность по шаблону маршрута gorilla/mux,
+// чтобы значения path-параметров не порождали отдельные ряды
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+		HTTPRequestSeconds.WithLabelValues(route, r.Method).ObserveSince(start)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
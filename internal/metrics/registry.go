@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry набор метрик, отдаваемых в текстовом формате Prometheus
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Handler отдаёт все метрики реестра
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		collectors := append([]collector(nil), r.collectors...)
+		r.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buf := bufio.NewWriter(w)
+		for _, c := range collectors {
+			c.write(buf)
+		}
+		buf.Flush()
+	})
+}
+
+// value число с плавающей точкой, изменяемое атомарно
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) set(x float64) {
+	v.bits.Store(math.Float64bits(x))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+// family общая часть метрик с метками: имя, описание и набор рядов
+type family[T any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.RWMutex
+	series map[string]*T
+	values map[string][]string
+	create func() *T
+}
+
+func newFamily[T any](name, help, kind string, labels []string, create func() *T) *family[T] {
+	return &family[T]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+		create: create,
+	}
+}
+
+// with возвращает ряд для значений меток, создавая его при первом обращении
+func (f *family[T]) with(labelValues ...string) *T {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.series[key]; ok {
+		return s
+	}
+	s = f.create()
+	f.series[key] = s
+	f.values[key] = append([]string(nil), labelValues...)
+	return s
+}
+
+// each обходит ряды в стабильном порядке
+func (f *family[T]) each(fn func(labelValues []string, s *T)) {
+	f.mu.RLock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	f.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f.mu.RLock()
+		s, labelValues := f.series[key], f.values[key]
+		f.mu.RUnlock()
+		fn(labelValues, s)
+	}
+}
+
+func (f *family[T]) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+}
+
+func writeSample(w *bufio.Writer, name string, labels, labelValues []string, extra string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extra != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, labelEscaper.Replace(labelValues[i]))
+		}
+		if extra != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extra)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// labelEscaper экранирует значения меток по правилам текстового формата Prometheus
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(r *Registry) string {
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+// Counters, gauges and histograms are rendered in the Prometheus text format
+func TestRegistryWritesTextFormat(t *testing.T) {
+	// Arrange
+	r := NewRegistry()
+	messages := r.NewCounterVec("messages_total", "Messages.", "result")
+	lag := r.NewGaugeVec("lag", "Lag.", "partition")
+	latency := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("entries", "Entries.", func() float64 { return 42 })
+
+	// Act
+	messages.WithLabelValues("consumed").Inc()
+	messages.WithLabelValues("consumed").Add(2)
+	lag.WithLabelValues("0").Set(7)
+	latency.WithLabelValues("/order/{order_uid}").Observe(0.05)
+	latency.WithLabelValues("/order/{order_uid}").Observe(0.5)
+	body := scrape(r)
+
+	// Assert
+	assert.Contains(t, body, "# TYPE messages_total counter\nmessages_total{result=\"consumed\"} 3\n")
+	assert.Contains(t, body, "lag{partition=\"0\"} 7\n")
+	assert.Contains(t, body, "latency_seconds_bucket{route=\"/order/{order_uid}\",le=\"0.1\"} 1\n")
+	assert.Contains(t, body, "latency_seconds_bucket{route=\"/order/{order_uid}\",le=\"1\"} 2\n")
+	assert.Contains(t, body, "latency_seconds_bucket{route=\"/order/{order_uid}\",le=\"+Inf\"} 2\n")
+	assert.Contains(t, body, "latency_seconds_count{route=\"/order/{order_uid}\"} 2\n")
+	assert.Contains(t, body, "# TYPE entries gauge\nentries 42\n")
+}
+
+// Label values are escaped
+func TestRegistryEscapesLabelValues(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("errors_total", "Errors.", "reason").WithLabelValues("say \"hi\"\n").Inc()
+
+	assert.Contains(t, scrape(r), `errors_total{reason="say \"hi\"\n"} 1`)
+}
+
+// The HTTP middleware labels requests with the route template, not the raw path
+func TestHTTPMiddlewareUsesRouteTemplate(t *testing.T) {
+	// Arrange
+	router := mux.NewRouter()
+	router.Use(HTTPMiddleware)
+	router.HandleFunc("/metrics-test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	// Act
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics-test/abc", nil))
+
+	// Assert
+	assert.Contains(t, scrape(Default), `orders_http_requests_total{route="/metrics-test/{id}",method="GET",code="404"} 1`)
+	assert.NotContains(t, scrape(Default), "/metrics-test/abc")
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefBuckets границы гистограмм длительности по умолчанию, в секундах
+var DefBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter монотонно растущий счётчик
+type Counter struct {
+	v value
+}
+
+func (c *Counter) Inc() {
+	c.v.add(1)
+}
+
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("counter cannot decrease")
+	}
+	c.v.add(delta)
+}
+
+// CounterVec счётчики с метками
+type CounterVec struct {
+	*family[Counter]
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	vec := &CounterVec{newFamily(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(vec)
+	return vec
+}
+
+func (c *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return c.with(labelValues...)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.each(func(labelValues []string, s *Counter) {
+		writeSample(w, c.name, c.labels, labelValues, "", s.v.get())
+	})
+}
+
+// Gauge значение, которое может как расти, так и уменьшаться
+type Gauge struct {
+	v value
+}
+
+func (g *Gauge) Set(x float64) {
+	g.v.set(x)
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.v.add(delta)
+}
+
+// GaugeVec значения с метками
+type GaugeVec struct {
+	*family[Gauge]
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	vec := &GaugeVec{newFamily(name, help, "gauge", labels, func() *Gauge { return &Gauge{} })}
+	r.register(vec)
+	return vec
+}
+
+func (g *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return g.with(labelValues...)
+}
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	g.each(func(labelValues []string, s *Gauge) {
+		writeSample(w, g.name, g.labels, labelValues, "", s.v.get())
+	})
+}
+
+// Histogram распределение наблюдаемых значений по корзинам
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     value
+}
+
+func (h *Histogram) Observe(x float64) {
+	i := sort.SearchFloat64s(h.buckets, x)
+	if i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	h.sum.add(x)
+}
+
+// ObserveSince записывает время, прошедшее с start, в секундах
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// HistogramVec гистограммы с метками
+type HistogramVec struct {
+	*family[Histogram]
+	buckets []float64
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	vec := &HistogramVec{
+		family: newFamily(name, help, "histogram", labels, func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets))}
+		}),
+		buckets: buckets,
+	}
+	r.register(vec)
+	return vec
+}
+
+func (h *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	return h.with(labelValues...)
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.each(func(labelValues []string, s *Histogram) {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i].Load()
+			writeSample(w, h.name+"_bucket", h.labels, labelValues, fmt.Sprintf(`le="%s"`, formatFloat(bound)), float64(cumulative))
+		}
+		count := s.count.Load()
+		writeSample(w, h.name+"_bucket", h.labels, labelValues, `le="+Inf"`, float64(count))
+		writeSample(w, h.name+"_sum", h.labels, labelValues, "", s.sum.get())
+		writeSample(w, h.name+"_count", h.labels, labelValues, "", float64(count))
+	})
+}
+
+// funcMetric значение без меток, вычисляемое в момент сбора метрик
+type funcMetric struct {
+	name string
+	help string
+	kind string
+	fn   func() float64
+}
+
+// NewGaugeFunc регистрирует показатель, значение которого возвращает fn
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{name: name, help: help, kind: "gauge", fn: fn})
+}
+
+// NewCounterFunc регистрирует счётчик, значение которого ведётся в другом месте и возвращается fn
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{name: name, help: help, kind: "counter", fn: fn})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	writeSample(w, m.name, nil, nil, "", m.fn())
+}
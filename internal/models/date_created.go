@@ -0,0 +1,68 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// dateLayouts форматы date_created, которые встречаются в старых сообщениях;
+// время без часового пояса считается временем UTC
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseDate разбирает дату в RFC 3339 или в одном из старых форматов
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date format %q", value)
+}
+
+// UnmarshalJSON разбирает заказ, принимая date_created в RFC 3339, в старых форматах
+// или числом секунд Unix. Заказ записывается в JSON с date_created в RFC 3339.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type plain Order
+	aux := struct {
+		*plain
+		DateCreated json.RawMessage `json:"date_created"`
+	}{plain: (*plain)(o)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	created, err := parseDateCreated(aux.DateCreated)
+	if err != nil {
+		return fmt.Errorf("date_created: %w", err)
+	}
+	o.DateCreated = created
+	return nil
+}
+
+func parseDateCreated(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return time.Time{}, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		seconds, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("must be a string or unix time, got %s", raw)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return ParseDate(value)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Legacy date_created formats are accepted and written back as RFC 3339
+func TestOrderDecodesLegacyDateCreated(t *testing.T) {
+	for raw, expected := range map[string]time.Time{
+		`"2021-11-26T06:22:19Z"`:      time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		`"2021-11-26T09:22:19+03:00"`: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		`"2021-11-26T06:22:19"`:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		`"2021-11-26 06:22:19"`:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		`"2021-11-26"`:                time.Date(2021, 11, 26, 0, 0, 0, 0, time.UTC),
+		`1637907739`:                  time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		`null`:                        {},
+	} {
+		var order Order
+		require.NoError(t, json.Unmarshal([]byte(`{"order_uid":"1","date_created":`+raw+`}`), &order), raw)
+		assert.True(t, expected.Equal(order.DateCreated), "%s: got %s", raw, order.DateCreated)
+		assert.Equal(t, "1", order.OrderUID)
+	}
+
+	var order Order
+	assert.Error(t, json.Unmarshal([]byte(`{"date_created":"26.11.2021"}`), &order))
+
+	order = Order{DateCreated: time.Date(2021, 11, 26, 9, 22, 19, 0, time.FixedZone("MSK", 3*60*60))}
+	data, err := json.Marshal(order)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"date_created":"2021-11-26T09:22:19+03:00"`)
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// DeadLetter сообщение, которое не удалось обработать и которое помещено в карантин
+type DeadLetter struct {
+	ID        int64  `json:"id"`
+	Stage     string `json:"stage"`
+	Error     string `json:"error"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Attempts  int    `json:"attempts"`
+	Key       string `json:"key"`
+	Payload   string `json:"payload"`
+	// Headers заголовки исходного сообщения, кроме служебных заголовков карантина
+	Headers    map[string]string `json:"headers,omitempty"`
+	FailedAt   time.Time         `json:"failed_at"`
+	RedrivenAt *time.Time        `json:"redriven_at,omitempty"`
+}
@@ -1,15 +1,17 @@
 package models
 
+import "github.com/ZnNr/WB-test-L0/internal/money"
+
 type Item struct {
-	ChrtID      int     `json:"chrt_id"`
-	TrackNumber string  `json:"track_number"`
-	Price       float64 `json:"price"`
-	Rid         string  `json:"rid"`
-	Name        string  `json:"name"`
-	Sale        float64 `json:"sale"`
-	Size        string  `json:"size"`
-	TotalPrice  float64 `json:"total_price"`
-	NmID        int     `json:"nm_id"`
-	Brand       string  `json:"brand"`
-	Status      int     `json:"status"`
+	ChrtID      int          `json:"chrt_id"`
+	TrackNumber string       `json:"track_number"`
+	Price       money.Amount `json:"price"`
+	Rid         string       `json:"rid"`
+	Name        string       `json:"name"`
+	Sale        float64      `json:"sale"`
+	Size        string       `json:"size"`
+	TotalPrice  money.Amount `json:"total_price"`
+	NmID        int          `json:"nm_id"`
+	Brand       string       `json:"brand"`
+	Status      int          `json:"status"`
 }
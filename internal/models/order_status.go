@@ -0,0 +1,87 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// OrderStatus этап жизненного цикла заказа
+type OrderStatus string
+
+const (
+	StatusCreated   OrderStatus = "created"
+	StatusPaid      OrderStatus = "paid"
+	StatusAssembled OrderStatus = "assembled"
+	StatusShipped   OrderStatus = "shipped"
+	StatusDelivered OrderStatus = "delivered"
+	StatusCancelled OrderStatus = "cancelled"
+	StatusReturned  OrderStatus = "returned"
+)
+
+// ErrInvalidTransition переход не разрешён таблицей переходов
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// orderStatuses статусы в порядке жизненного цикла
+var orderStatuses = []OrderStatus{
+	StatusCreated, StatusPaid, StatusAssembled, StatusShipped, StatusDelivered, StatusCancelled, StatusReturned,
+}
+
+// statusTransitions допустимые переходы: до отгрузки заказ можно отменить, после — только вернуть.
+// Из cancelled и returned переходов нет.
+var statusTransitions = map[OrderStatus][]OrderStatus{
+	StatusCreated:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusAssembled, StatusCancelled},
+	StatusAssembled: {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered, StatusReturned},
+	StatusDelivered: {StatusReturned},
+}
+
+// StatusChange переход заказа в статус
+type StatusChange struct {
+	Status    OrderStatus `json:"status"`
+	ChangedAt time.Time   `json:"changed_at"`
+	// Reason причина перехода, например отмены или возврата
+	Reason string `json:"reason,omitempty"`
+}
+
+// OrderStatuses возвращает все статусы в порядке жизненного цикла
+func OrderStatuses() []OrderStatus {
+	return slices.Clone(orderStatuses)
+}
+
+// ParseOrderStatus проверяет, что s — известный статус
+func ParseOrderStatus(s string) (OrderStatus, error) {
+	status := OrderStatus(s)
+	if !status.Valid() {
+		return "", fmt.Errorf("unknown order status %q", s)
+	}
+	return status, nil
+}
+
+// Valid проверяет, что статус известен
+func (s OrderStatus) Valid() bool {
+	return slices.Contains(orderStatuses, s)
+}
+
+// Final проверяет, что из статуса больше нет переходов
+func (s OrderStatus) Final() bool {
+	return s.Valid() && len(statusTransitions[s]) == 0
+}
+
+// CanTransitionTo проверяет, что из статуса s разрешён переход в next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	return slices.Contains(statusTransitions[s], next)
+}
+
+// ValidateTransition возвращает ErrInvalidTransition, если переход from → to не разрешён
+func ValidateTransition(from, to OrderStatus) error {
+	if !to.Valid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, to)
+	}
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
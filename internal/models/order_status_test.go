@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Follows the happy path and allows cancelling before shipment and returning after it
+func TestValidateTransitionAllowsLifecycle(t *testing.T) {
+	for _, transition := range [][2]OrderStatus{
+		{StatusCreated, StatusPaid},
+		{StatusPaid, StatusAssembled},
+		{StatusAssembled, StatusShipped},
+		{StatusShipped, StatusDelivered},
+		{StatusCreated, StatusCancelled},
+		{StatusAssembled, StatusCancelled},
+		{StatusShipped, StatusReturned},
+		{StatusDelivered, StatusReturned},
+	} {
+		assert.NoError(t, ValidateTransition(transition[0], transition[1]), "%s -> %s", transition[0], transition[1])
+	}
+}
+
+// Rejects skipped steps, moves back, leaving final statuses and unknown statuses
+func TestValidateTransitionRejectsInvalid(t *testing.T) {
+	for _, transition := range [][2]OrderStatus{
+		{StatusCreated, StatusShipped},
+		{StatusPaid, StatusCreated},
+		{StatusShipped, StatusCancelled},
+		{StatusCancelled, StatusPaid},
+		{StatusReturned, StatusDelivered},
+		{StatusCreated, "lost"},
+		{"", StatusPaid},
+	} {
+		assert.ErrorIs(t, ValidateTransition(transition[0], transition[1]), ErrInvalidTransition, "%s -> %s", transition[0], transition[1])
+	}
+}
+
+// Only known statuses parse, and only cancelled and returned are final
+func TestParseOrderStatus(t *testing.T) {
+	for _, status := range OrderStatuses() {
+		parsed, err := ParseOrderStatus(string(status))
+		require.NoError(t, err)
+		assert.Equal(t, status, parsed)
+		assert.Equal(t, status == StatusCancelled || status == StatusReturned, status.Final(), status)
+	}
+
+	_, err := ParseOrderStatus("Paid")
+	assert.Error(t, err)
+}
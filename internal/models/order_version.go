@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// OrderVersion заменённая версия заказа
+type OrderVersion struct {
+	Version    int64     `json:"version"`
+	Order      Order     `json:"order"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
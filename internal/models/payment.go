@@ -1,14 +1,16 @@
 package models
 
+import "github.com/ZnNr/WB-test-L0/internal/money"
+
 type Payment struct {
-	Transaction  string  `json:"transaction"`
-	RequestID    string  `json:"request_id"`
-	Currency     string  `json:"currency"`
-	Provider     string  `json:"provider"`
-	Amount       float64 `json:"amount"`
-	PaymentDT    int     `json:"payment_dt"`
-	Bank         string  `json:"bank"`
-	DeliveryCost float64 `json:"delivery_cost"`
-	GoodsTotal   float64 `json:"goods_total"`
-	CustomFee    float64 `json:"custom_fee"`
+	Transaction  string       `json:"transaction"`
+	RequestID    string       `json:"request_id"`
+	Currency     string       `json:"currency"`
+	Provider     string       `json:"provider"`
+	Amount       money.Amount `json:"amount"`
+	PaymentDT    int          `json:"payment_dt"`
+	Bank         string       `json:"bank"`
+	DeliveryCost money.Amount `json:"delivery_cost"`
+	GoodsTotal   money.Amount `json:"goods_total"`
+	CustomFee    money.Amount `json:"custom_fee"`
 }
@@ -0,0 +1,207 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale число знаков после запятой, которые хранит Amount; хватает для любой валюты ISO 4217
+const Scale = 4
+
+const unit = 10_000
+
+// ErrInvalidAmount возвращается для строк, которые не являются точной десятичной суммой
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Amount точная денежная сумма в десятитысячных долях основной единицы валюты.
+// В JSON и в БД записывается десятичным числом в основных единицах: 453, 317.1, 0.05.
+type Amount int64
+
+// New возвращает сумму из целого числа основных единиц
+func New(major int64) Amount {
+	return Amount(major * unit)
+}
+
+// FromMinor возвращает сумму из минорных единиц валюты, например копеек
+func FromMinor(minor int64, currency Currency) Amount {
+	return Amount(minor * pow10(Scale-currency.Exponent))
+}
+
+// Parse разбирает десятичную запись суммы без потери точности
+func Parse(s string) (Amount, error) {
+	raw := s
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" || len(fraction) > Scale || !digits(whole) || !digits(fraction) {
+		return 0, fmt.Errorf("%w %q", ErrInvalidAmount, raw)
+	}
+
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || major > math.MaxInt64/unit {
+		return 0, fmt.Errorf("%w %q: out of range", ErrInvalidAmount, raw)
+	}
+	var frac int64
+	if fraction != "" {
+		frac, _ = strconv.ParseInt(fraction+strings.Repeat("0", Scale-len(fraction)), 10, 64)
+	}
+
+	amount := Amount(major*unit + frac)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// MustParse как Parse, но паникует на ошибке; для констант и тестов
+func MustParse(s string) Amount {
+	amount, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String возвращает десятичную запись суммы без лишних нулей
+func (a Amount) String() string {
+	sign := ""
+	value := uint64(a)
+	if a < 0 {
+		sign = "-"
+		value = uint64(-a)
+	}
+	major, frac := value/unit, value%unit
+	if frac == 0 {
+		return sign + strconv.FormatUint(major, 10)
+	}
+	fraction := strings.TrimRight(fmt.Sprintf("%0*d", Scale, frac), "0")
+	return sign + strconv.FormatUint(major, 10) + "." + fraction
+}
+
+// Minor возвращает сумму в минорных единицах валюты.
+// Если в сумме больше знаков после запятой, чем допускает валюта, возвращает ошибку.
+func (a Amount) Minor(currency Currency) (int64, error) {
+	step := pow10(Scale - currency.Exponent)
+	if int64(a)%step != 0 {
+		return 0, fmt.Errorf("%s has more than %d decimal places allowed for %s", a, currency.Exponent, currency.Code)
+	}
+	return int64(a) / step, nil
+}
+
+// Fits сообщает, представима ли сумма в минорных единицах валюты
+func (a Amount) Fits(currency Currency) bool {
+	_, err := a.Minor(currency)
+	return err == nil
+}
+
+// Discount возвращает сумму со скидкой percent процентов, отбрасывая доли меньше десятитысячной
+func (a Amount) Discount(percent float64) Amount {
+	// Скидка хранится с точностью до сотых процента, поэтому считаем в целых долях
+	basisPoints := int64(math.Round(percent * 100))
+	result := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(10_000-basisPoints))
+	return Amount(result.Quo(result, big.NewInt(10_000)).Int64())
+}
+
+// Truncate отбрасывает доли меньше минорной единицы валюты
+func (a Amount) Truncate(currency Currency) Amount {
+	step := pow10(Scale - currency.Exponent)
+	return a - Amount(int64(a)%step)
+}
+
+// Abs возвращает модуль суммы
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Float64 возвращает приближённое значение суммы; только для отображения и метрик
+func (a Amount) Float64() float64 {
+	return float64(a) / unit
+}
+
+// MarshalJSON записывает сумму JSON-числом
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON принимает JSON-число или строку с десятичной записью суммы
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+	amount, err := Parse(s)
+	if err != nil {
+		// Значения вроде 1e3 допустимы в JSON; принимаем их, если они точно представимы
+		if f, ferr := strconv.ParseFloat(s, 64); ferr == nil && math.Abs(f) < math.MaxInt64/unit {
+			if scaled := f * unit; scaled == math.Trunc(scaled) {
+				*a = Amount(scaled)
+				return nil
+			}
+		}
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// Value записывает сумму в БД десятичной строкой, чтобы NUMERIC сохранил её точно
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan читает сумму из NUMERIC, целочисленного столбца или NULL
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case int64:
+		*a = New(v)
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case float64:
+		*a = Amount(math.Round(v * unit))
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for range n {
+		result *= 10
+	}
+	return result
+}
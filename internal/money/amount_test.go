@@ -0,0 +1,99 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Decimal strings round-trip without losing precision
+func TestParseAndString(t *testing.T) {
+	for input, expected := range map[string]string{
+		"453":       "453",
+		"317.10":    "317.1",
+		"0.05":      "0.05",
+		"-12.3456":  "-12.3456",
+		"1500.0000": "1500",
+	} {
+		amount, err := Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, amount.String())
+	}
+
+	for _, input := range []string{"", "abc", "1.23456", "1,5", ".5", "1e3"} {
+		_, err := Parse(input)
+		assert.ErrorIs(t, err, ErrInvalidAmount, input)
+	}
+}
+
+// JSON numbers are decoded exactly, including values that float64 cannot represent
+func TestAmountJSON(t *testing.T) {
+	var payload struct {
+		Amount Amount `json:"amount"`
+		Fee    Amount `json:"fee"`
+		Cost   Amount `json:"cost"`
+	}
+
+	err := json.Unmarshal([]byte(`{"amount": 90071992547409.93, "fee": "0.1", "cost": 1e3}`), &payload)
+
+	require.NoError(t, err)
+	assert.Equal(t, "90071992547409.93", payload.Amount.String())
+	assert.Equal(t, MustParse("0.1"), payload.Fee)
+	assert.Equal(t, New(1000), payload.Cost)
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 90071992547409.93, "fee": 0.1, "cost": 1000}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 0.00001}`), &payload))
+}
+
+// Minor units depend on the currency exponent
+func TestMinorUnits(t *testing.T) {
+	usd, _ := LookupCurrency("USD")
+	jpy, _ := LookupCurrency("JPY")
+	kwd, _ := LookupCurrency("KWD")
+
+	minor, err := MustParse("12.34").Minor(usd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1234), minor)
+	assert.Equal(t, MustParse("12.34"), FromMinor(1234, usd))
+
+	minor, err = MustParse("1.234").Minor(kwd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1234), minor)
+
+	_, err = MustParse("12.5").Minor(jpy)
+	assert.Error(t, err)
+	assert.Equal(t, New(12), MustParse("12.5").Truncate(jpy))
+
+	_, ok := LookupCurrency("XYZ")
+	assert.False(t, ok)
+}
+
+// Discounts are computed in exact arithmetic
+func TestDiscount(t *testing.T) {
+	assert.Equal(t, MustParse("317.1"), New(453).Discount(30))
+	assert.Equal(t, MustParse("0.7"), MustParse("0.99").Discount(29.29).Truncate(Currency{Exponent: 1}))
+	assert.Equal(t, New(100), New(100).Discount(0))
+}
+
+// Values from NUMERIC and legacy INTEGER columns are scanned exactly
+func TestScan(t *testing.T) {
+	var amount Amount
+
+	require.NoError(t, amount.Scan([]byte("317.1000")))
+	assert.Equal(t, MustParse("317.1"), amount)
+
+	require.NoError(t, amount.Scan(int64(1817)))
+	assert.Equal(t, New(1817), amount)
+
+	require.NoError(t, amount.Scan(nil))
+	assert.Equal(t, Amount(0), amount)
+
+	value, err := MustParse("0.05").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "0.05", value)
+}
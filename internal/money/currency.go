@@ -0,0 +1,39 @@
+package money
+
+import "strings"
+
+// Currency валюта ISO 4217; Exponent — число знаков минорной единицы (2 для копеек, 0 для иен)
+type Currency struct {
+	Code     string
+	Exponent int
+}
+
+// Действующие валюты ISO 4217, сгруппированные по числу знаков минорной единицы
+var (
+	zeroDecimal  = strings.Fields("BIF CLP DJF GNF ISK JPY KMF KRW PYG RWF UGX VND VUV XAF XOF XPF")
+	threeDecimal = strings.Fields("BHD IQD JOD KWD LYD OMR TND")
+	twoDecimal   = strings.Fields(`
+AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BMD BND BOB BRL BSD BTN BWP BYN BZD
+CAD CDF CHF CNY COP CRC CUP CVE CZK DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD
+GTQ GYD HKD HNL HTG HUF IDR ILS INR IRR JMD KES KGS KHR KPW KYD KZT LAK LBP LKR LRD LSL
+MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR NZD PAB PEN PGK
+PHP PKR PLN QAR RON RSD RUB SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB
+TJS TMT TOP TRY TTD TWD TZS UAH USD UYU UZS VES WST XCD YER ZAR ZMW ZWL
+`)
+)
+
+var currencies = make(map[string]Currency)
+
+func init() {
+	for exponent, codes := range [][]string{zeroDecimal, nil, twoDecimal, threeDecimal} {
+		for _, code := range codes {
+			currencies[code] = Currency{Code: code, Exponent: exponent}
+		}
+	}
+}
+
+// LookupCurrency возвращает валюту по коду ISO 4217
+func LookupCurrency(code string) (Currency, bool) {
+	currency, ok := currencies[code]
+	return currency, ok
+}
@@ -3,31 +3,37 @@ package order_gen
 import (
 	"fmt"
 	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/money"
 	"github.com/google/uuid"
 	"math/rand"
 	"time"
 )
 
 func GenerateOrder() models.Order {
+	trackNumber := randomString(10)
 	delivery := generateDelivery()
-	item := generateItem()
-	payment := generatePayment()
+	currency := generateCurrency()
+	items := make([]models.Item, rand.Intn(3)+1)
+	for i := range items {
+		items[i] = generateItem(trackNumber, currency)
+	}
+	payment := generatePayment(items, currency)
 	locale := generateLocale()
 
 	order := models.Order{
 		OrderUID:          uuid.New().String(),
-		TrackNumber:       randomString(10),
+		TrackNumber:       trackNumber,
 		Entry:             randomString(5),
 		Delivery:          delivery,
 		Payment:           payment,
-		Items:             []models.Item{item},
+		Items:             items,
 		Locale:            locale,
 		InternalSignature: randomString(8),
 		CustomerID:        randomString(8),
 		DeliveryService:   randomString(5),
 		Shardkey:          randomString(5),
-		SmID:              rand.Intn(100),
-		DateCreated:       time.Now().Format("2006-01-02"),
+		SmID:              rand.Intn(100) + 1,
+		DateCreated:       time.Now().UTC().Truncate(time.Microsecond),
 		OofShard:          randomString(4),
 	}
 	return order
@@ -45,40 +51,55 @@ func generateDelivery() models.Delivery {
 	}
 }
 
-func generateItem() models.Item {
+// Итоговая цена товара согласована с ценой и скидкой, трек-номер — с заказом,
+// суммы точно представимы в минорных единицах валюты
+func generateItem(trackNumber string, currency money.Currency) models.Item {
+	price := money.FromMinor(int64(rand.Intn(100000)+1), currency)
+	sale := float64(rand.Intn(100))
 	return models.Item{
-		ChrtID:      rand.Intn(1000),
-		TrackNumber: randomString(10),
-		Price:       float64(rand.Intn(1000)),
+		ChrtID:      rand.Intn(1000) + 1,
+		TrackNumber: trackNumber,
+		Price:       price,
 		Rid:         randomString(6),
 		Name:        randomString(10),
-		Sale:        float64(rand.Intn(100)),
+		Sale:        sale,
 		Size:        randomSize(),
-		TotalPrice:  float64(rand.Intn(1000)),
-		NmID:        rand.Intn(1000),
+		TotalPrice:  price.Discount(sale).Truncate(currency),
+		NmID:        rand.Intn(1000) + 1,
 		Brand:       randomString(8),
 		Status:      rand.Intn(5),
 	}
 }
 
-func generatePayment() models.Payment {
-	currencies := []string{"USD", "RUB", "EUR"}
-	currency := currencies[rand.Intn(len(currencies))]
+// Суммы платежа согласованы с товарами заказа
+func generatePayment(items []models.Item, currency money.Currency) models.Payment {
+	var goodsTotal money.Amount
+	for _, item := range items {
+		goodsTotal += item.TotalPrice
+	}
+	deliveryCost := money.New(int64(rand.Intn(500)))
+	customFee := money.New(int64(rand.Intn(100)))
 
 	return models.Payment{
 		Transaction:  uuid.New().String(),
 		RequestID:    uuid.New().String(),
-		Currency:     currency,
+		Currency:     currency.Code,
 		Provider:     randomString(6),
-		Amount:       float64(rand.Intn(10000)),
+		Amount:       goodsTotal + deliveryCost + customFee,
 		PaymentDT:    int(time.Now().Unix()),
 		Bank:         randomString(6),
-		DeliveryCost: float64(rand.Intn(500)),
-		GoodsTotal:   float64(rand.Intn(10000)),
-		CustomFee:    float64(rand.Intn(100)),
+		DeliveryCost: deliveryCost,
+		GoodsTotal:   goodsTotal,
+		CustomFee:    customFee,
 	}
 }
 
+func generateCurrency() money.Currency {
+	codes := []string{"USD", "RUB", "EUR", "JPY"}
+	currency, _ := money.LookupCurrency(codes[rand.Intn(len(codes))])
+	return currency
+}
+
 func generateLocale() string {
 	locales := []string{"en", "ru"}
 	return locales[rand.Intn(len(locales))]
@@ -0,0 +1,124 @@
+package query
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/money"
+)
+
+// sortKey значение, по которому упорядочиваются заказы; order_uid разрешает равенство значений
+type sortKey struct {
+	date     time.Time
+	amount   money.Amount
+	orderUID string
+}
+
+// Apply отбирает, сортирует и разбивает на страницы заказы, находящиеся в памяти
+func Apply(orders []models.Order, params Params) (Page, error) {
+	matched := make([]models.Order, 0, len(orders))
+	for _, order := range orders {
+		if params.Match(order) {
+			matched = append(matched, order)
+		}
+	}
+
+	slices.SortFunc(matched, func(a, b models.Order) int {
+		return params.compare(keyOf(a, params.Sort), keyOf(b, params.Sort))
+	})
+
+	start := 0
+	if params.Cursor != nil {
+		after, err := params.Cursor.key()
+		if err != nil {
+			return Page{}, err
+		}
+		start = sort.Search(len(matched), func(i int) bool {
+			return params.compare(keyOf(matched[i], params.Sort), after) > 0
+		})
+	}
+
+	end := min(start+params.Limit, len(matched))
+	page := Page{Orders: matched[start:end], Total: len(matched)}
+	if end < len(matched) {
+		page.NextCursor = CursorFor(matched[end-1], params.Sort).Encode()
+	}
+	return page, nil
+}
+
+// keyOf возвращает значение поля сортировки заказа
+func keyOf(order models.Order, field SortField) sortKey {
+	key := sortKey{orderUID: order.OrderUID}
+	switch field {
+	case SortByDateCreated:
+		key.date = order.DateCreated
+	case SortByAmount:
+		key.amount = order.Payment.Amount
+	}
+	return key
+}
+
+// CursorFor возвращает курсор, указывающий на заказ
+func CursorFor(order models.Order, field SortField) Cursor {
+	key := keyOf(order, field)
+	cursor := Cursor{Sort: field, OrderUID: order.OrderUID}
+	switch field {
+	case SortByDateCreated:
+		cursor.Value = key.date.Format(time.RFC3339Nano)
+	case SortByAmount:
+		cursor.Value = key.amount.String()
+	}
+	return cursor
+}
+
+// DateValue значение курсора для сортировки по date_created
+func (c Cursor) DateValue() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return time.Time{}, ErrInvalidCursor
+	}
+	return t, nil
+}
+
+// AmountValue значение курсора для сортировки по payment.amount
+func (c Cursor) AmountValue() (money.Amount, error) {
+	amount, err := money.Parse(c.Value)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return amount, nil
+}
+
+func (c Cursor) key() (sortKey, error) {
+	key := sortKey{orderUID: c.OrderUID}
+	var err error
+	switch c.Sort {
+	case SortByDateCreated:
+		key.date, err = c.DateValue()
+	case SortByAmount:
+		key.amount, err = c.AmountValue()
+	}
+	return key, err
+}
+
+// compare сравнивает ключи с учётом направления сортировки
+func (p Params) compare(a, b sortKey) int {
+	var result int
+	switch p.Sort {
+	case SortByDateCreated:
+		result = a.date.Compare(b.date)
+	case SortByAmount:
+		result = cmp.Compare(a.amount, b.amount)
+	}
+	if result == 0 {
+		result = strings.Compare(a.orderUID, b.orderUID)
+	}
+	if p.Desc {
+		return -result
+	}
+	return result
+}
@@ -0,0 +1,197 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+)
+
+// SortField поле, по которому сортируется список заказов
+type SortField string
+
+const (
+	SortByDateCreated SortField = "date_created"
+	SortByAmount      SortField = "payment.amount"
+	SortByOrderUID    SortField = "order_uid"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 1000
+)
+
+// dateOnlyLayout формат date_to без времени: такая граница включает весь указанный день
+const dateOnlyLayout = "2006-01-02"
+
+// ErrInvalidCursor возвращается, если курсор повреждён или выдан для другой сортировки
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Filter условия отбора заказов; пустые поля не учитываются
+type Filter struct {
+	CustomerID  string
+	TrackNumber string
+	City        string
+	Region      string
+	Currency    string
+	Provider    string
+	Locale      string
+	DateFrom    *time.Time
+	DateTo      *time.Time
+	// DateToExclusive заказ, созданный ровно в DateTo, не подходит; так задаётся конец дня для date_to без времени
+	DateToExclusive bool
+	// Statuses заказ подходит, если его статус — любой из перечисленных
+	Statuses []models.OrderStatus
+}
+
+// Params параметры запроса списка заказов
+type Params struct {
+	Filter
+	Sort   SortField
+	Desc   bool
+	Limit  int
+	Cursor *Cursor
+}
+
+// Page страница списка заказов
+type Page struct {
+	Orders     []models.Order `json:"orders"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Total      int            `json:"total"`
+}
+
+// Cursor позиция последнего заказа страницы: значение поля сортировки и order_uid
+type Cursor struct {
+	Sort     SortField `json:"s"`
+	Value    string    `json:"v"`
+	OrderUID string    `json:"u"`
+}
+
+// Encode превращает курсор в непрозрачную строку для клиента
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбирает строку, полученную из Cursor.Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.OrderUID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Parse читает параметры запроса списка заказов из строки запроса.
+// sort принимает date_created, payment.amount или order_uid, с префиксом "-" для сортировки по убыванию.
+// status можно повторить или перечислить через запятую.
+// date_to без времени включает весь день, с временем — включительная граница.
+func Parse(values url.Values) (Params, error) {
+	params := Params{
+		Filter: Filter{
+			CustomerID:  values.Get("customer_id"),
+			TrackNumber: values.Get("track_number"),
+			City:        values.Get("city"),
+			Region:      values.Get("region"),
+			Currency:    values.Get("currency"),
+			Provider:    values.Get("provider"),
+			Locale:      values.Get("locale"),
+		},
+		Sort:  SortByOrderUID,
+		Limit: DefaultLimit,
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		params.Desc = strings.HasPrefix(sort, "-")
+		params.Sort = SortField(strings.TrimPrefix(sort, "-"))
+		switch params.Sort {
+		case SortByDateCreated, SortByAmount, SortByOrderUID:
+		default:
+			return Params{}, fmt.Errorf("unknown sort field %q", params.Sort)
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			return Params{}, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+		params.Limit = n
+	}
+
+	for _, value := range values["status"] {
+		for _, name := range strings.Split(value, ",") {
+			status, err := models.ParseOrderStatus(strings.TrimSpace(name))
+			if err != nil {
+				return Params{}, err
+			}
+			if !slices.Contains(params.Statuses, status) {
+				params.Statuses = append(params.Statuses, status)
+			}
+		}
+	}
+
+	for name, dest := range map[string]**time.Time{"date_from": &params.DateFrom, "date_to": &params.DateTo} {
+		if value := values.Get(name); value != "" {
+			t, err := models.ParseDate(value)
+			if err != nil {
+				return Params{}, fmt.Errorf("%s must be a date (2006-01-02) or RFC 3339 timestamp", name)
+			}
+			*dest = &t
+		}
+	}
+	if value := values.Get("date_to"); value != "" {
+		if day, err := time.Parse(dateOnlyLayout, value); err == nil {
+			end := day.AddDate(0, 0, 1)
+			params.DateTo = &end
+			params.DateToExclusive = true
+		}
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return Params{}, err
+		}
+		if c.Sort != params.Sort {
+			return Params{}, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, c.Sort)
+		}
+		params.Cursor = c
+	}
+
+	return params, nil
+}
+
+// Match проверяет, подходит ли заказ под фильтр
+func (f Filter) Match(order models.Order) bool {
+	switch {
+	case f.CustomerID != "" && order.CustomerID != f.CustomerID,
+		f.TrackNumber != "" && order.TrackNumber != f.TrackNumber,
+		f.City != "" && order.Delivery.City != f.City,
+		f.Region != "" && order.Delivery.Region != f.Region,
+		f.Currency != "" && order.Payment.Currency != f.Currency,
+		f.Provider != "" && order.Payment.Provider != f.Provider,
+		f.Locale != "" && order.Locale != f.Locale,
+		len(f.Statuses) > 0 && !slices.Contains(f.Statuses, order.Status):
+		return false
+	}
+
+	if f.DateFrom != nil && order.DateCreated.Before(*f.DateFrom) {
+		return false
+	}
+	if f.DateTo != nil && (order.DateCreated.After(*f.DateTo) || f.DateToExclusive && order.DateCreated.Equal(*f.DateTo)) {
+		return false
+	}
+	return true
+}
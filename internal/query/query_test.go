@@ -0,0 +1,163 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(value string) time.Time {
+	t, err := models.ParseDate(value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func testOrders() []models.Order {
+	return []models.Order{
+		{OrderUID: "a", CustomerID: "c1", DateCreated: date("2024-01-03T10:00:00Z"), Payment: models.Payment{Amount: money.New(300), Currency: "RUB"}, Delivery: models.Delivery{City: "Moscow"}},
+		{OrderUID: "b", CustomerID: "c2", DateCreated: date("2024-01-01T10:00:00Z"), Payment: models.Payment{Amount: money.New(100), Currency: "USD"}, Delivery: models.Delivery{City: "Kazan"}},
+		{OrderUID: "c", CustomerID: "c1", DateCreated: date("2024-01-02T10:00:00Z"), Payment: models.Payment{Amount: money.New(200), Currency: "RUB"}, Delivery: models.Delivery{City: "Moscow"}},
+		{OrderUID: "d", CustomerID: "c1", DateCreated: date("2024-01-04"), Payment: models.Payment{Amount: money.New(200), Currency: "RUB"}, Delivery: models.Delivery{City: "Kazan"}},
+	}
+}
+
+func orderUIDs(orders []models.Order) []string {
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		uids[i] = order.OrderUID
+	}
+	return uids
+}
+
+// Pages through sorted orders with a cursor until the last page
+func TestApplyPaginatesWithCursor(t *testing.T) {
+	// Arrange
+	params, err := Parse(url.Values{"sort": {"-payment.amount"}, "limit": {"2"}})
+	require.NoError(t, err)
+
+	// Act
+	first, err := Apply(testOrders(), params)
+	require.NoError(t, err)
+
+	params, err = Parse(url.Values{"sort": {"-payment.amount"}, "limit": {"2"}, "cursor": {first.NextCursor}})
+	require.NoError(t, err)
+	second, err := Apply(testOrders(), params)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, []string{"a", "d"}, orderUIDs(first.Orders))
+	assert.Equal(t, 4, first.Total)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.Equal(t, []string{"c", "b"}, orderUIDs(second.Orders))
+	assert.Empty(t, second.NextCursor)
+}
+
+// Filters by customer, city, currency and date range
+func TestApplyFilters(t *testing.T) {
+	// Arrange
+	params, err := Parse(url.Values{
+		"customer_id": {"c1"},
+		"currency":    {"RUB"},
+		"city":        {"Moscow"},
+		"date_from":   {"2024-01-02"},
+		"sort":        {"date_created"},
+	})
+	require.NoError(t, err)
+
+	// Act
+	page, err := Apply(testOrders(), params)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, orderUIDs(page.Orders))
+	assert.Equal(t, 2, page.Total)
+}
+
+// A date-only date_to includes the whole day, while a timestamp is an inclusive bound
+func TestApplyDateToIncludesWholeDay(t *testing.T) {
+	// Arrange
+	orders := []models.Order{
+		{OrderUID: "noon", DateCreated: date("2024-01-05T12:00:00Z")},
+		{OrderUID: "midnight", DateCreated: date("2024-01-05T00:00:00Z")},
+		{OrderUID: "next", DateCreated: date("2024-01-06T00:00:00Z")},
+	}
+	dateOnly, err := Parse(url.Values{"date_to": {"2024-01-05"}})
+	require.NoError(t, err)
+	timestamp, err := Parse(url.Values{"date_to": {"2024-01-05T12:00:00Z"}})
+	require.NoError(t, err)
+
+	// Act
+	byDay, err := Apply(orders, dateOnly)
+	require.NoError(t, err)
+	byTimestamp, err := Apply(orders, timestamp)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, []string{"midnight", "noon"}, orderUIDs(byDay.Orders))
+	assert.Equal(t, []string{"midnight", "noon"}, orderUIDs(byTimestamp.Orders))
+	assert.True(t, dateOnly.DateToExclusive)
+	assert.False(t, timestamp.DateToExclusive)
+}
+
+// Filters by any of the requested statuses, given repeated or comma-separated
+func TestApplyFiltersByStatus(t *testing.T) {
+	// Arrange
+	orders := testOrders()
+	for i, status := range []models.OrderStatus{models.StatusPaid, models.StatusCreated, models.StatusShipped, models.StatusPaid} {
+		orders[i].Status = status
+	}
+	params, err := Parse(url.Values{"status": {"paid, shipped", "paid"}})
+	require.NoError(t, err)
+
+	// Act
+	page, err := Apply(orders, params)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []models.OrderStatus{models.StatusPaid, models.StatusShipped}, params.Statuses)
+	assert.Equal(t, []string{"a", "c", "d"}, orderUIDs(page.Orders))
+}
+
+// Rejects unknown sort fields and statuses, bad limits and cursors issued for another sort
+func TestParseRejectsInvalidParams(t *testing.T) {
+	cursor := Cursor{Sort: SortByOrderUID, OrderUID: "a"}.Encode()
+
+	for _, values := range []url.Values{
+		{"sort": {"name"}},
+		{"limit": {"0"}},
+		{"limit": {"100000"}},
+		{"date_to": {"yesterday"}},
+		{"status": {"paid,lost"}},
+		{"cursor": {"garbage"}},
+		{"sort": {"date_created"}, "cursor": {cursor}},
+	} {
+		_, err := Parse(values)
+		assert.Error(t, err, "values: %v", values)
+	}
+}
+
+// Sorts by the full timestamp, including time of day and zone offset
+func TestApplySortsByTimestamp(t *testing.T) {
+	// Arrange
+	orders := []models.Order{
+		{OrderUID: "late", DateCreated: date("2024-01-01T12:30:00+03:00")},
+		{OrderUID: "early", DateCreated: date("2024-01-01T10:00:00+03:00")},
+		{OrderUID: "utc", DateCreated: date("2024-01-01T08:30:00Z")},
+	}
+	params, err := Parse(url.Values{"sort": {"date_created"}, "date_to": {"2024-01-01T09:00:00Z"}})
+	require.NoError(t, err)
+
+	// Act
+	page, err := Apply(orders, params)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"early", "utc"}, orderUIDs(page.Orders))
+}
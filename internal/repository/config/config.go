@@ -1,32 +1,155 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"github.com/IBM/sarama"
 	"github.com/ilyakaznacheev/cleanenv"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Config настройки сервиса. Значения читаются из YAML-файла,
+// а переменные окружения из тегов env их переопределяют.
 type Config struct {
-	DB    ConfigDB    `yaml:"db"`
-	App   ConfigApp   `yaml:"app"`
-	Kafka KafkaConfig `yaml:"kafka"`
+	DB      ConfigDB      `yaml:"db"`
+	Storage StorageConfig `yaml:"storage"`
+	App     ConfigApp     `yaml:"app"`
+	Kafka   KafkaConfig   `yaml:"kafka"`
+	Broker  BrokerConfig  `yaml:"broker"`
+	Cache   CacheConfig   `yaml:"cache"`
+	Auth    AuthConfig    `yaml:"auth"`
 }
 
 type ConfigApp struct {
-	Host string `yaml:"host"`
-	Port string `yaml:"port"`
+	Host string `yaml:"host" env:"APP_HOST"`
+	Port string `yaml:"port" env:"APP_PORT"`
+	// HealthCheckTimeout ограничение времени каждой проверки /readyz
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" env:"APP_HEALTH_CHECK_TIMEOUT" env-default:"2s"`
+	// ShutdownTimeout ограничение времени каждого этапа остановки сервиса
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"APP_SHUTDOWN_TIMEOUT" env-default:"15s"`
+	// ReadTimeout время на чтение запроса целиком, включая тело
+	ReadTimeout time.Duration `yaml:"read_timeout" env:"APP_READ_TIMEOUT" env-default:"10s"`
+	// WriteTimeout время на запись ответа
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"APP_WRITE_TIMEOUT" env-default:"30s"`
+	// IdleTimeout сколько держать открытым keep-alive соединение между запросами
+	IdleTimeout time.Duration `yaml:"idle_timeout" env:"APP_IDLE_TIMEOUT" env-default:"120s"`
+	// CORSAllowedOrigins источники, которым разрешены запросы из браузера; пустой список запрещает все
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins" env:"APP_CORS_ALLOWED_ORIGINS"`
 }
 
 type ConfigDB struct {
-	Port     string `yaml:"port"`
-	Host     string `yaml:"host"`
-	Name     string `yaml:"name"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
+	Port     string `yaml:"port" env:"DB_PORT"`
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD"`
+	// AutoMigrate применять недостающие миграции при старте сервиса
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE" env-default:"true"`
+	// QueryTimeout предельное время одной операции с БД; при потоковом чтении — одной пачки
+	QueryTimeout time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" env-default:"5s"`
+}
+
+// StorageConfig выбор хранилища заказов и карантина
+type StorageConfig struct {
+	// Type хранилище: postgres (настройки в ConfigDB) или memory (в памяти процесса, данные теряются при перезапуске)
+	Type string `yaml:"type" env:"STORAGE_TYPE" env-default:"postgres"`
 }
 
 type KafkaConfig struct {
-	Brokers []string `yaml:"brokers"`
-	Topic   string   `yaml:"topic"`
+	Brokers []string `yaml:"brokers" env:"KAFKA_BROKERS"`
+	Topic   string   `yaml:"topic" env:"KAFKA_TOPIC"`
+	// ClientID имя клиента, которым сервис представляется брокерам
+	ClientID string `yaml:"client_id" env:"KAFKA_CLIENT_ID" env-default:"orders-service"`
+	// Version версия протокола Kafka, например 2.8.0
+	Version string `yaml:"version" env:"KAFKA_VERSION" env-default:"1.0.0"`
+	// DialTimeout ограничение времени подключения к брокеру
+	DialTimeout time.Duration `yaml:"dial_timeout" env:"KAFKA_DIAL_TIMEOUT" env-default:"30s"`
+	// ReadTimeout ограничение времени ожидания ответа брокера
+	ReadTimeout time.Duration `yaml:"read_timeout" env:"KAFKA_READ_TIMEOUT" env-default:"30s"`
+	// WriteTimeout ограничение времени отправки запроса брокеру
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"KAFKA_WRITE_TIMEOUT" env-default:"30s"`
+	// GroupID идентификатор consumer group, под которым сервис коммитит смещения
+	GroupID string `yaml:"group_id" env:"KAFKA_GROUP_ID" env-default:"orders-service"`
+	// SessionTimeout через сколько без heartbeat участник группы считается выбывшим
+	SessionTimeout time.Duration `yaml:"session_timeout" env:"KAFKA_SESSION_TIMEOUT" env-default:"10s"`
+	// HeartbeatInterval период отправки heartbeat координатору группы
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" env:"KAFKA_HEARTBEAT_INTERVAL" env-default:"3s"`
+	// InitialOffset откуда читать партицию без закоммиченного смещения: oldest или newest
+	InitialOffset string `yaml:"initial_offset" env:"KAFKA_INITIAL_OFFSET" env-default:"oldest"`
+	// RebalanceStrategy стратегия распределения партиций: range, roundrobin или sticky
+	RebalanceStrategy string `yaml:"rebalance_strategy" env:"KAFKA_REBALANCE_STRATEGY" env-default:"range"`
+	// DLQTopic топик, в который отправляются сообщения, которые не удалось обработать
+	DLQTopic string `yaml:"dlq_topic" env:"KAFKA_DLQ_TOPIC" env-default:"orders.dlq"`
+	// EventsTopic топик событий об изменении заказов, например об удалении
+	EventsTopic string `yaml:"events_topic" env:"KAFKA_EVENTS_TOPIC" env-default:"orders.events"`
+	// MaxAttempts сколько раз пытаться сохранить заказ, прежде чем отправить сообщение в DLQTopic
+	MaxAttempts int `yaml:"max_attempts" env:"KAFKA_MAX_ATTEMPTS" env-default:"5"`
+}
+
+// BrokerConfig выбор брокера сообщений. Топики и группа берутся из KafkaConfig для любого брокера.
+type BrokerConfig struct {
+	// Type брокер сообщений: kafka, memory (в памяти процесса) или file (NDJSON-файлы в Dir)
+	Type string `yaml:"type" env:"BROKER_TYPE" env-default:"kafka"`
+	// Dir каталог с файлами топиков для брокера file
+	Dir string `yaml:"dir" env:"BROKER_DIR" env-default:"data/broker"`
+	// PollInterval как часто брокер file проверяет файлы топиков на новые строки
+	PollInterval time.Duration `yaml:"poll_interval" env:"BROKER_POLL_INTERVAL" env-default:"500ms"`
+}
+
+type CacheConfig struct {
+	// MaxEntries максимальное число заказов в кэше; 0 — без ограничения
+	MaxEntries int `yaml:"max_entries" env:"CACHE_MAX_ENTRIES" env-default:"100000"`
+	// MaxBytes оценочный объём памяти под заказы в байтах; 0 — без ограничения
+	MaxBytes int64 `yaml:"max_bytes" env:"CACHE_MAX_BYTES" env-default:"268435456"`
+	// Policy политика вытеснения: lru или lfu
+	Policy string `yaml:"policy" env:"CACHE_POLICY" env-default:"lru"`
+	// WarmupBatchSize сколько заказов загружать из БД за один проход при прогреве кэша
+	WarmupBatchSize int `yaml:"warmup_batch_size" env:"CACHE_WARMUP_BATCH_SIZE" env-default:"1000"`
+	// NegativeTTL сколько помнить, что заказа нет в БД, прежде чем снова его искать
+	NegativeTTL time.Duration `yaml:"negative_ttl" env:"CACHE_NEGATIVE_TTL" env-default:"5s"`
+}
+
+// PathEnv переменная окружения, задающая путь к файлу конфигурации
+const PathEnv = "CONFIG_PATH"
+
+// Path возвращает путь из PathEnv, а если она не задана — defaultPath
+func Path(defaultPath string) string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	return defaultPath
+}
+
+type AuthConfig struct {
+	// Enabled требовать аутентификацию для API заказов и карантина; включённой нужен хотя бы один ключ или секрет JWT
+	Enabled bool `yaml:"enabled" env:"AUTH_ENABLED" env-default:"false"`
+	// APIKeys статические ключи в формате subject:role:key, роль — reader, operator или admin
+	APIKeys []string `yaml:"api_keys" env:"AUTH_API_KEYS"`
+	// JWTSecret ключ HMAC для проверки подписи JWT (HS256); пустой — JWT не принимаются
+	JWTSecret string `yaml:"jwt_secret" env:"AUTH_JWT_SECRET"`
+	// JWTIssuer ожидаемое значение iss; пустой — не проверяется
+	JWTIssuer string `yaml:"jwt_issuer" env:"AUTH_JWT_ISSUER"`
+	// InsecureAllowAnonymous при выключенной аутентификации открыть всем и маршруты operator и admin;
+	// без этого флага выключенная аутентификация оставляет доступным только чтение
+	InsecureAllowAnonymous bool `yaml:"insecure_allow_anonymous" env:"AUTH_INSECURE_ALLOW_ANONYMOUS"`
+}
+
+// minJWTSecretLength минимальная длина ключа HMAC, соответствующая размеру SHA-256
+const minJWTSecretLength = 32
+
+// ParseAPIKey разбирает ключ API в формате subject:role:key
+func ParseAPIKey(entry string) (subject, role, key string, err error) {
+	parts := strings.SplitN(entry, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", "", errors.New("expected subject:role:key")
+	}
+	if !oneOf(parts[1], "reader", "operator", "admin") {
+		return "", "", "", fmt.Errorf("role %q is not one of reader, operator, admin", parts[1])
+	}
+	return parts[0], parts[1], parts[2], nil
 }
 
 func Load(cfgPath string) (*Config, error) {
@@ -35,5 +158,130 @@ func Load(cfgPath string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", cfgPath, err)
+	}
 	return &cfg, nil
 }
+
+// Validate проверяет настройки и перечисляет все найденные ошибки
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.App.Port), "app.port: %q is not a valid port", c.App.Port)
+	check(c.App.HealthCheckTimeout > 0, "app.health_check_timeout: must be positive")
+	check(c.App.ShutdownTimeout > 0, "app.shutdown_timeout: must be positive")
+	check(c.App.ReadTimeout > 0, "app.read_timeout: must be positive")
+	check(c.App.WriteTimeout > 0, "app.write_timeout: must be positive")
+	check(c.App.IdleTimeout > 0, "app.idle_timeout: must be positive")
+
+	check(oneOf(c.Storage.Type, "postgres", "memory"),
+		"storage.type: %q is not one of postgres, memory", c.Storage.Type)
+	if c.Storage.Type == "postgres" {
+		check(c.DB.Host != "", "db.host: must be set")
+		check(validPort(c.DB.Port), "db.port: %q is not a valid port", c.DB.Port)
+		check(c.DB.Name != "", "db.name: must be set")
+		check(c.DB.User != "", "db.user: must be set")
+		check(c.DB.QueryTimeout > 0, "db.query_timeout: must be positive")
+	}
+
+	check(oneOf(c.Broker.Type, "kafka", "memory", "file"),
+		"broker.type: %q is not one of kafka, memory, file", c.Broker.Type)
+	if c.Broker.Type == "file" {
+		check(c.Broker.Dir != "", "broker.dir: must be set for the file broker")
+		check(c.Broker.PollInterval > 0, "broker.poll_interval: must be positive")
+	}
+
+	if c.Broker.Type == "kafka" {
+		check(len(c.Kafka.Brokers) > 0, "kafka.brokers: at least one broker is required")
+	}
+	for i, broker := range c.Kafka.Brokers {
+		check(broker != "", "kafka.brokers[%d]: must not be empty", i)
+	}
+	check(c.Kafka.Topic != "", "kafka.topic: must be set")
+	check(c.Kafka.GroupID != "", "kafka.group_id: must be set")
+	check(c.Kafka.DLQTopic != "", "kafka.dlq_topic: must be set")
+	check(c.Kafka.DLQTopic != c.Kafka.Topic, "kafka.dlq_topic: must differ from kafka.topic")
+	check(c.Kafka.EventsTopic != "", "kafka.events_topic: must be set")
+	check(c.Kafka.EventsTopic != c.Kafka.Topic, "kafka.events_topic: must differ from kafka.topic")
+	if _, err := sarama.ParseKafkaVersion(c.Kafka.Version); err != nil {
+		errs = append(errs, fmt.Errorf("kafka.version: %w", err))
+	}
+	check(c.Kafka.DialTimeout > 0, "kafka.dial_timeout: must be positive")
+	check(c.Kafka.ReadTimeout > 0, "kafka.read_timeout: must be positive")
+	check(c.Kafka.WriteTimeout > 0, "kafka.write_timeout: must be positive")
+	check(c.Kafka.SessionTimeout > 0, "kafka.session_timeout: must be positive")
+	check(c.Kafka.HeartbeatInterval > 0 && c.Kafka.HeartbeatInterval < c.Kafka.SessionTimeout,
+		"kafka.heartbeat_interval: must be positive and less than kafka.session_timeout")
+	check(oneOf(c.Kafka.InitialOffset, "oldest", "newest"),
+		"kafka.initial_offset: %q is not one of oldest, newest", c.Kafka.InitialOffset)
+	check(oneOf(c.Kafka.RebalanceStrategy, "range", "roundrobin", "sticky"),
+		"kafka.rebalance_strategy: %q is not one of range, roundrobin, sticky", c.Kafka.RebalanceStrategy)
+	check(c.Kafka.MaxAttempts >= 1, "kafka.max_attempts: must be at least 1")
+
+	check(c.Cache.MaxEntries >= 0, "cache.max_entries: must not be negative")
+	check(c.Cache.MaxBytes >= 0, "cache.max_bytes: must not be negative")
+	check(oneOf(c.Cache.Policy, "lru", "lfu"), "cache.policy: %q is not one of lru, lfu", c.Cache.Policy)
+	check(c.Cache.WarmupBatchSize > 0, "cache.warmup_batch_size: must be positive")
+	check(c.Cache.NegativeTTL >= 0, "cache.negative_ttl: must not be negative")
+
+	if c.Auth.Enabled {
+		check(len(c.Auth.APIKeys) > 0 || c.Auth.JWTSecret != "",
+			"auth: api_keys or jwt_secret must be set when auth is enabled")
+		for i, entry := range c.Auth.APIKeys {
+			// Сам ключ в сообщение не попадает
+			if _, _, _, err := ParseAPIKey(entry); err != nil {
+				errs = append(errs, fmt.Errorf("auth.api_keys[%d]: %w", i, err))
+			}
+		}
+		check(c.Auth.JWTSecret == "" || len(c.Auth.JWTSecret) >= minJWTSecretLength,
+			"auth.jwt_secret: must be at least %d bytes", minJWTSecretLength)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Sarama общие настройки клиента Kafka: идентификатор, версия протокола и таймауты сети
+func (k KafkaConfig) Sarama() (*sarama.Config, error) {
+	saramaCfg := sarama.NewConfig()
+	saramaCfg.Version = sarama.V1_0_0_0
+	if k.Version != "" {
+		version, err := sarama.ParseKafkaVersion(k.Version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kafka version: %w", err)
+		}
+		saramaCfg.Version = version
+	}
+	if k.ClientID != "" {
+		saramaCfg.ClientID = k.ClientID
+	}
+	if k.DialTimeout > 0 {
+		saramaCfg.Net.DialTimeout = k.DialTimeout
+	}
+	if k.ReadTimeout > 0 {
+		saramaCfg.Net.ReadTimeout = k.ReadTimeout
+	}
+	if k.WriteTimeout > 0 {
+		saramaCfg.Net.WriteTimeout = k.WriteTimeout
+	}
+	return saramaCfg, nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
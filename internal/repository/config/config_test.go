@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+app:
+  host: localhost
+  port: 8080
+db:
+  host: localhost
+  port: 5432
+  name: orders_db
+  user: my_user
+  password: my_password
+kafka:
+  brokers:
+    - localhost:9092
+  topic: orders
+auth:
+  api_keys:
+    - ops:admin:secret-key
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// Defaults fill the settings missing from the file
+func TestLoadAppliesDefaults(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig))
+
+	require.NoError(t, err)
+	assert.Equal(t, "orders-service", cfg.Kafka.ClientID)
+	assert.Equal(t, "1.0.0", cfg.Kafka.Version)
+	assert.Equal(t, "orders.dlq", cfg.Kafka.DLQTopic)
+	assert.Equal(t, "lru", cfg.Cache.Policy)
+	assert.Positive(t, cfg.App.ReadTimeout)
+	assert.Equal(t, 5*time.Second, cfg.DB.QueryTimeout)
+	assert.Equal(t, "kafka", cfg.Broker.Type)
+	assert.Equal(t, "postgres", cfg.Storage.Type)
+	assert.Equal(t, 500*time.Millisecond, cfg.Broker.PollInterval)
+}
+
+// Environment variables override the file
+func TestLoadAppliesEnvironmentOverrides(t *testing.T) {
+	t.Setenv("APP_PORT", "9090")
+	t.Setenv("KAFKA_BROKERS", "kafka-1:9092,kafka-2:9092")
+	t.Setenv("KAFKA_SESSION_TIMEOUT", "45s")
+
+	cfg, err := Load(writeConfig(t, testConfig))
+
+	require.NoError(t, err)
+	assert.Equal(t, "9090", cfg.App.Port)
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, cfg.Kafka.Brokers)
+	assert.Equal(t, "45s", cfg.Kafka.SessionTimeout.String())
+}
+
+// Every invalid setting is reported at once
+func TestLoadReportsAllInvalidSettings(t *testing.T) {
+	t.Setenv("APP_PORT", "http")
+	t.Setenv("KAFKA_VERSION", "latest")
+	t.Setenv("CACHE_POLICY", "fifo")
+
+	_, err := Load(writeConfig(t, testConfig))
+
+	require.Error(t, err)
+	assert.ErrorContains(t, err, `app.port: "http" is not a valid port`)
+	assert.ErrorContains(t, err, "kafka.version:")
+	assert.ErrorContains(t, err, `cache.policy: "fifo" is not one of lru, lfu`)
+}
+
+// Kafka brokers are only required when the broker type is kafka
+func TestLoadBrokerTypeWithoutKafka(t *testing.T) {
+	content := strings.Replace(testConfig, "  brokers:\n    - localhost:9092\n", "", 1)
+
+	_, err := Load(writeConfig(t, content))
+	assert.ErrorContains(t, err, "kafka.brokers: at least one broker is required")
+
+	t.Setenv("BROKER_TYPE", "file")
+	t.Setenv("BROKER_DIR", "")
+	_, err = Load(writeConfig(t, content))
+	assert.ErrorContains(t, err, "broker.dir: must be set for the file broker")
+	assert.NotContains(t, err.Error(), "kafka.brokers")
+
+	t.Setenv("BROKER_TYPE", "memory")
+	cfg, err := Load(writeConfig(t, content))
+	require.NoError(t, err)
+	assert.Equal(t, "memory", cfg.Broker.Type)
+
+	t.Setenv("BROKER_TYPE", "rabbitmq")
+	_, err = Load(writeConfig(t, content))
+	assert.ErrorContains(t, err, `broker.type: "rabbitmq" is not one of kafka, memory, file`)
+}
+
+// Database settings are only required when the storage type is postgres
+func TestLoadMemoryStorageWithoutDB(t *testing.T) {
+	content := strings.Replace(testConfig, "  host: localhost\n  port: 5432\n", "", 1)
+
+	_, err := Load(writeConfig(t, content))
+	assert.ErrorContains(t, err, "db.host: must be set")
+
+	t.Setenv("STORAGE_TYPE", "memory")
+	cfg, err := Load(writeConfig(t, content))
+	require.NoError(t, err)
+	assert.Equal(t, "memory", cfg.Storage.Type)
+
+	t.Setenv("STORAGE_TYPE", "sqlite")
+	_, err = Load(writeConfig(t, content))
+	assert.ErrorContains(t, err, `storage.type: "sqlite" is not one of postgres, memory`)
+}
+
+// Auth is off unless enabled, and once enabled it needs credentials from the environment
+func TestLoadAuthRequiresCredentialsWhenEnabled(t *testing.T) {
+	content := strings.Replace(testConfig, "auth:\n  api_keys:\n    - ops:admin:secret-key\n", "", 1)
+
+	cfg, err := Load(writeConfig(t, content))
+	require.NoError(t, err)
+	assert.False(t, cfg.Auth.Enabled)
+
+	t.Setenv("AUTH_ENABLED", "true")
+	_, err = Load(writeConfig(t, content))
+	assert.ErrorContains(t, err, "auth: api_keys or jwt_secret must be set when auth is enabled")
+
+	t.Setenv("AUTH_API_KEYS", "ops:admin:secret-key")
+	cfg, err = Load(writeConfig(t, content))
+	require.NoError(t, err)
+	assert.True(t, cfg.Auth.Enabled)
+	assert.Equal(t, []string{"ops:admin:secret-key"}, cfg.Auth.APIKeys)
+}
+
+// The shipped config loads without credentials, keeps auth off and does not open write routes
+func TestShippedConfigHasNoCredentials(t *testing.T) {
+	cfg, err := Load(filepath.Join("..", "..", "..", "config", "config.yaml"))
+
+	require.NoError(t, err)
+	assert.False(t, cfg.Auth.Enabled)
+	assert.False(t, cfg.Auth.InsecureAllowAnonymous)
+	assert.Empty(t, cfg.Auth.APIKeys)
+	assert.Empty(t, cfg.Auth.JWTSecret)
+}
+
+// CONFIG_PATH takes precedence over the default path
+func TestPathPrefersEnvironment(t *testing.T) {
+	assert.Equal(t, "config/config.yaml", Path("config/config.yaml"))
+
+	t.Setenv(PathEnv, "/etc/orders/config.yaml")
+	assert.Equal(t, "/etc/orders/config.yaml", Path("config/config.yaml"))
+}
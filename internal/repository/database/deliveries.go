@@ -1,11 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 
 	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/lib/pq"
 )
 
 const (
@@ -23,11 +25,14 @@ const (
     RETURNING order_uid`
 
 	getDeliveryQuery = `SELECT * FROM deliveries WHERE order_uid = $1`
+
+	getDeliveriesByOrdersQuery = `SELECT order_uid, name, phone, zip, city, address, region, email
+    FROM deliveries WHERE order_uid = ANY($1)`
 )
 
-func AddDelivery(db *sql.DB, delivery models.Delivery, orderUID string) (string, error) {
+func AddDelivery(ctx context.Context, db Executor, delivery models.Delivery, orderUID string) (string, error) {
 	// Проверяем, существует ли доставка с данным order_uid
-	existingDelivery, err := GetDelivery(db, orderUID)
+	existingDelivery, err := GetDelivery(ctx, db, orderUID)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return "", fmt.Errorf("не удалось получить доставку: %w", err)
 	}
@@ -35,7 +40,7 @@ func AddDelivery(db *sql.DB, delivery models.Delivery, orderUID string) (string,
 	var operationMessage string
 	var returnedOrderUID string
 
-	err = db.QueryRow(
+	err = db.QueryRowContext(ctx,
 		addDeliveryQuery,
 		delivery.Name,
 		delivery.Phone,
@@ -62,8 +67,8 @@ func AddDelivery(db *sql.DB, delivery models.Delivery, orderUID string) (string,
 	return operationMessage, nil
 }
 
-func GetDelivery(db *sql.DB, orderUID string) (*models.Delivery, error) {
-	row := db.QueryRow(getDeliveryQuery, orderUID)
+func GetDelivery(ctx context.Context, db Executor, orderUID string) (*models.Delivery, error) {
+	row := db.QueryRowContext(ctx, getDeliveryQuery, orderUID)
 
 	var delivery models.Delivery
 	err := row.Scan(
@@ -86,3 +91,35 @@ func GetDelivery(db *sql.DB, orderUID string) (*models.Delivery, error) {
 
 	return &delivery, nil
 }
+
+// GetDeliveriesByOrders получает доставки сразу для нескольких заказов одним запросом
+func GetDeliveriesByOrders(ctx context.Context, db Executor, orderUIDs []string) (map[string]models.Delivery, error) {
+	rows, err := db.QueryContext(ctx, getDeliveriesByOrdersQuery, pq.Array(orderUIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make(map[string]models.Delivery, len(orderUIDs))
+	for rows.Next() {
+		var delivery models.Delivery
+		if err := rows.Scan(
+			&delivery.OrderUID,
+			&delivery.Name,
+			&delivery.Phone,
+			&delivery.Zip,
+			&delivery.City,
+			&delivery.Address,
+			&delivery.Region,
+			&delivery.Email,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery row: %w", err)
+		}
+		deliveries[delivery.OrderUID] = delivery
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iteration over rows failed: %w", err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,14 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Executor общий интерфейс *sql.DB и *sql.Tx, чтобы функции пакета
+// могли выполняться как отдельно, так и в рамках транзакции
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
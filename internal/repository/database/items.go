@@ -1,49 +1,43 @@
 package database
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"github.com/ZnNr/WB-test-L0/internal/models"
-	"strconv"
+	"github.com/lib/pq"
 )
 
 const (
-	addItemQuery = `INSERT INTO items ("chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status", "order_uid") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ON CONFLICT (chrt_id) DO NOTHING`
+	addItemQuery = `INSERT INTO items ("chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status", "order_uid", "line_no") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) ON CONFLICT (order_uid, line_no) DO NOTHING`
 
-	getAllItemsQuery = "SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = $1"
-)
+	getAllItemsQuery = "SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = $1 ORDER BY line_no"
 
-// AddItems сохраняет список элементов заказа в БД, пропуская существующие элементы
-func AddItems(db *sql.DB, items []models.Item, orderUID string) error {
-	for _, item := range items {
-		exists, err := ItemExists(db, strconv.Itoa(item.ChrtID), orderUID) // Проверка существования
-		if err != nil {
-			return fmt.Errorf("failed to check existence: %w", err)
-		}
+	getItemsByOrdersQuery = "SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = ANY($1) ORDER BY order_uid, line_no"
+)
 
-		if !exists {
-			err = AddItem(db, item, orderUID)
-			if err != nil {
-				return fmt.Errorf("failed to add item: %w", err)
-			}
+// AddItems сохраняет список элементов заказа в БД, пропуская уже сохранённые строки.
+// Строка товара определяется заказом и позицией в списке, поэтому одинаковые chrt_id
+// в разных заказах и внутри одного заказа не мешают друг другу.
+func AddItems(ctx context.Context, db Executor, items []models.Item, orderUID string) error {
+	for i, item := range items {
+		if err := AddItem(ctx, db, item, orderUID, i+1); err != nil {
+			return fmt.Errorf("failed to add item: %w", err)
 		}
 	}
 	return nil
 }
 
-// ItemExists проверяет, существует ли элемент в БД
-func ItemExists(db *sql.DB, chrtID string, orderUID string) (bool, error) {
-	var exists bool
-	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM items WHERE chrt_id = $1 AND order_uid = $2)`, chrtID, orderUID).Scan(&exists)
-	if err != nil {
-		return false, fmt.Errorf("failed to check if item exists: %w", err)
+// DeleteItems удаляет все товары заказа
+func DeleteItems(ctx context.Context, db Executor, orderUID string) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM items WHERE order_uid = $1`, orderUID); err != nil {
+		return fmt.Errorf("failed to delete items: %w", err)
 	}
-	return exists, nil
+	return nil
 }
 
-// AddItem добавляет новый элемент в БД
-func AddItem(db *sql.DB, item models.Item, orderUID string) error {
-	_, err := db.Exec(
+// AddItem добавляет новый элемент в БД строкой lineNo заказа; строки нумеруются с 1
+func AddItem(ctx context.Context, db Executor, item models.Item, orderUID string, lineNo int) error {
+	_, err := db.ExecContext(ctx,
 		addItemQuery,
 		item.ChrtID,
 		item.TrackNumber,
@@ -57,6 +51,7 @@ func AddItem(db *sql.DB, item models.Item, orderUID string) error {
 		item.Brand,
 		item.Status,
 		orderUID,
+		lineNo,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to execute add item query: %w", err)
@@ -64,9 +59,9 @@ func AddItem(db *sql.DB, item models.Item, orderUID string) error {
 	return nil
 }
 
-// GetItems получает все элементы из БД по идентификатору заказа
-func GetItems(db *sql.DB, orderUID string) ([]models.Item, error) {
-	rows, err := db.Query(getAllItemsQuery, orderUID)
+// GetItems получает все элементы из БД по идентификатору заказа; у заказа без товаров список пуст
+func GetItems(ctx context.Context, db Executor, orderUID string) ([]models.Item, error) {
+	rows, err := db.QueryContext(ctx, getAllItemsQuery, orderUID)
 	if err != nil {
 		return nil, fmt.Errorf("get items failed: %w", err)
 	}
@@ -98,11 +93,44 @@ func GetItems(db *sql.DB, orderUID string) ([]models.Item, error) {
 		return nil, fmt.Errorf("iteration over rows failed: %w", err)
 	}
 
-	if len(items) == 0 {
-		// Логируем ненайденный orderUID
-		fmt.Printf("No items found for order UID %s\n", orderUID)
-		return nil, fmt.Errorf("items not found for order UID %s", orderUID)
+	return items, nil
+}
+
+// GetItemsByOrders получает товары сразу для нескольких заказов одним запросом
+func GetItemsByOrders(ctx context.Context, db Executor, orderUIDs []string) (map[string][]models.Item, error) {
+	rows, err := db.QueryContext(ctx, getItemsByOrdersQuery, pq.Array(orderUIDs))
+	if err != nil {
+		return nil, fmt.Errorf("get items failed: %w", err)
 	}
+	defer rows.Close()
 
+	items := make(map[string][]models.Item, len(orderUIDs))
+	for rows.Next() {
+		var (
+			orderUID string
+			item     models.Item
+		)
+		if err := rows.Scan(
+			&orderUID,
+			&item.ChrtID,
+			&item.TrackNumber,
+			&item.Price,
+			&item.Rid,
+			&item.Name,
+			&item.Sale,
+			&item.Size,
+			&item.TotalPrice,
+			&item.NmID,
+			&item.Brand,
+			&item.Status,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		items[orderUID] = append(items[orderUID], item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iteration over rows failed: %w", err)
+	}
 	return items, nil
 }
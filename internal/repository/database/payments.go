@@ -1,10 +1,12 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/lib/pq"
 )
 
 const (
@@ -20,15 +22,30 @@ const (
     "goods_total", 
     "custom_fee" 
 FROM payments WHERE order_uid = $1`
+	getPaymentsByOrdersQuery = `SELECT order_uid, transaction, request_id, currency, provider, amount,
+    payment_dt, bank, delivery_cost, goods_total, custom_fee
+    FROM payments WHERE order_uid = ANY($1)`
 	addPaymentQuery = `INSERT INTO payments
         ("transaction", "request_id", "currency", "provider", "amount",
         "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee", "order_uid")
         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	upsertPaymentQuery = addPaymentQuery + `
+        ON CONFLICT (order_uid) DO UPDATE SET
+            transaction = EXCLUDED.transaction,
+            request_id = EXCLUDED.request_id,
+            currency = EXCLUDED.currency,
+            provider = EXCLUDED.provider,
+            amount = EXCLUDED.amount,
+            payment_dt = EXCLUDED.payment_dt,
+            bank = EXCLUDED.bank,
+            delivery_cost = EXCLUDED.delivery_cost,
+            goods_total = EXCLUDED.goods_total,
+            custom_fee = EXCLUDED.custom_fee`
 )
 
 // AddPayment добавляет платеж в базу данных.
-func AddPayment(db *sql.DB, payment models.Payment, orderUID string) error {
-	_, err := db.Exec(
+func AddPayment(ctx context.Context, db Executor, payment models.Payment, orderUID string) error {
+	_, err := db.ExecContext(ctx,
 		addPaymentQuery,
 		payment.Transaction,
 		payment.RequestID,
@@ -48,9 +65,31 @@ func AddPayment(db *sql.DB, payment models.Payment, orderUID string) error {
 	return nil
 }
 
+// UpsertPayment добавляет платеж или заменяет существующий платеж заказа.
+func UpsertPayment(ctx context.Context, db Executor, payment models.Payment, orderUID string) error {
+	_, err := db.ExecContext(ctx,
+		upsertPaymentQuery,
+		payment.Transaction,
+		payment.RequestID,
+		payment.Currency,
+		payment.Provider,
+		payment.Amount,
+		payment.PaymentDT,
+		payment.Bank,
+		payment.DeliveryCost,
+		payment.GoodsTotal,
+		payment.CustomFee,
+		orderUID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert payment: %w", err)
+	}
+	return nil
+}
+
 // GetPayment получает платеж из базы данных по orderUID.
-func GetPayment(db *sql.DB, orderUID string) (*models.Payment, error) {
-	row := db.QueryRow(getPaymentQuery, orderUID) // Используем tx
+func GetPayment(ctx context.Context, db Executor, orderUID string) (*models.Payment, error) {
+	row := db.QueryRowContext(ctx, getPaymentQuery, orderUID) // Используем tx
 	var payment models.Payment
 
 	err := row.Scan(
@@ -75,11 +114,49 @@ func GetPayment(db *sql.DB, orderUID string) (*models.Payment, error) {
 }
 
 // PaymentExists проверяет существование платежа в базе данных по orderUID.
-func PaymentExists(tx *sql.DB, orderUID string) (bool, error) {
+func PaymentExists(ctx context.Context, tx Executor, orderUID string) (bool, error) {
 	var exists bool
-	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM payments WHERE order_uid = $1)", orderUID).Scan(&exists)
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM payments WHERE order_uid = $1)", orderUID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("could not verify the existence of the payment: %w", err) // Улучшено сообщение об ошибке
 	}
 	return exists, nil
 }
+
+// GetPaymentsByOrders получает платежи сразу для нескольких заказов одним запросом.
+func GetPaymentsByOrders(ctx context.Context, db Executor, orderUIDs []string) (map[string]models.Payment, error) {
+	rows, err := db.QueryContext(ctx, getPaymentsByOrdersQuery, pq.Array(orderUIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+	defer rows.Close()
+
+	payments := make(map[string]models.Payment, len(orderUIDs))
+	for rows.Next() {
+		var (
+			orderUID string
+			payment  models.Payment
+		)
+		if err := rows.Scan(
+			&orderUID,
+			&payment.Transaction,
+			&payment.RequestID,
+			&payment.Currency,
+			&payment.Provider,
+			&payment.Amount,
+			&payment.PaymentDT,
+			&payment.Bank,
+			&payment.DeliveryCost,
+			&payment.GoodsTotal,
+			&payment.CustomFee,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan payment row: %w", err)
+		}
+		payments[orderUID] = payment
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iteration over rows failed: %w", err)
+	}
+	return payments, nil
+}
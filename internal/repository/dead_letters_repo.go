@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+)
+
+const (
+	addDeadLetterQuery = `INSERT INTO dead_letters
+    ("stage", "error", "topic", "partition", "offset", "attempts", "key", "payload", "headers")
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    ON CONFLICT ("topic", "partition", "offset") DO UPDATE SET stage = EXCLUDED.stage, error = EXCLUDED.error,
+        attempts = EXCLUDED.attempts, key = EXCLUDED.key, payload = EXCLUDED.payload, headers = EXCLUDED.headers,
+        failed_at = now(), redriven_at = NULL
+    RETURNING id, failed_at`
+	getDeadLetterQuery = `SELECT id, stage, error, topic, partition, "offset", attempts, key, payload, headers, failed_at, redriven_at
+    FROM dead_letters WHERE id = $1`
+	getDeadLettersQuery = `SELECT id, stage, error, topic, partition, "offset", attempts, key, payload, headers, failed_at, redriven_at
+    FROM dead_letters ORDER BY id DESC LIMIT $1 OFFSET $2`
+	markDeadLetterRedrivenQuery = `UPDATE dead_letters SET redriven_at = now() WHERE id = $1`
+)
+
+// DeadLettersRepo хранилище сообщений, помещённых в карантин
+type DeadLettersRepo struct {
+	DB *sql.DB
+	// Timeout предельное время одной операции; ноль не ограничивает
+	Timeout time.Duration
+}
+
+func NewDeadLettersRepo(db *sql.DB, timeout time.Duration) *DeadLettersRepo {
+	return &DeadLettersRepo{DB: db, Timeout: timeout}
+}
+
+// AddDeadLetter сохраняет сообщение и заполняет ID и FailedAt.
+// Сообщение с теми же topic, partition и offset не дублируется, поэтому Send можно повторять,
+// пока публикация не удастся. Строка перезаписывается целиком и снова считается неотправленной:
+// брокеры в памяти и в файле начинают смещения с нуля, и под тем же смещением может оказаться другое сообщение.
+func (d *DeadLettersRepo) AddDeadLetter(ctx context.Context, letter *models.DeadLetter) error {
+	ctx, cancel := withTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	headers, err := json.Marshal(letter.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter headers: %w", err)
+	}
+	if letter.Headers == nil {
+		headers = []byte("{}")
+	}
+
+	err = d.DB.QueryRowContext(ctx, addDeadLetterQuery,
+		letter.Stage, letter.Error, letter.Topic, letter.Partition, letter.Offset,
+		letter.Attempts, []byte(letter.Key), []byte(letter.Payload), headers,
+	).Scan(&letter.ID, &letter.FailedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert dead letter: %w", err)
+	}
+	return nil
+}
+
+// GetDeadLetter возвращает сообщение по ID или nil, если его нет
+func (d *DeadLettersRepo) GetDeadLetter(ctx context.Context, id int64) (*models.DeadLetter, error) {
+	ctx, cancel := withTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	letter, err := scanDeadLetter(d.DB.QueryRowContext(ctx, getDeadLetterQuery, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+	return letter, nil
+}
+
+// GetDeadLetters возвращает страницу сообщений, начиная с самых свежих
+func (d *DeadLettersRepo) GetDeadLetters(ctx context.Context, limit, offset int) ([]models.DeadLetter, error) {
+	ctx, cancel := withTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	rows, err := d.DB.QueryContext(ctx, getDeadLettersQuery, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := make([]models.DeadLetter, 0, limit)
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter row: %w", err)
+		}
+		letters = append(letters, *letter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iteration over rows failed: %w", err)
+	}
+	return letters, nil
+}
+
+// MarkRedriven отмечает время повторной отправки сообщения в основной топик
+func (d *DeadLettersRepo) MarkRedriven(ctx context.Context, id int64) error {
+	ctx, cancel := withTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	if _, err := d.DB.ExecContext(ctx, markDeadLetterRedrivenQuery, id); err != nil {
+		return fmt.Errorf("failed to mark dead letter as redriven: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDeadLetter(row rowScanner) (*models.DeadLetter, error) {
+	var (
+		letter     models.DeadLetter
+		key        []byte
+		payload    []byte
+		headers    []byte
+		redrivenAt sql.NullTime
+	)
+	if err := row.Scan(&letter.ID, &letter.Stage, &letter.Error, &letter.Topic, &letter.Partition,
+		&letter.Offset, &letter.Attempts, &key, &payload, &headers, &letter.FailedAt, &redrivenAt); err != nil {
+		return nil, err
+	}
+	letter.Key = string(key)
+	letter.Payload = string(payload)
+	if err := json.Unmarshal(headers, &letter.Headers); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter headers: %w", err)
+	}
+	if len(letter.Headers) == 0 {
+		letter.Headers = nil
+	}
+	if redrivenAt.Valid {
+		letter.RedrivenAt = &redrivenAt.Time
+	}
+	return &letter, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Saving the same source message again updates the stored letter instead of adding a duplicate
+func TestAddDeadLetterIsIdempotent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewDeadLettersRepo(newTestRepo(t).DB, 5*time.Second)
+	offset := time.Now().UnixNano()
+	letter := func(attempts int) *models.DeadLetter {
+		return &models.DeadLetter{Stage: "store", Error: "db is down", Topic: "orders-test", Partition: 0, Offset: offset, Attempts: attempts}
+	}
+	first, second := letter(1), letter(2)
+	t.Cleanup(func() {
+		_, _ = repo.DB.Exec(`DELETE FROM dead_letters WHERE topic = 'orders-test' AND "offset" = $1`, offset)
+	})
+
+	// Act
+	require.NoError(t, repo.AddDeadLetter(ctx, first))
+	require.NoError(t, repo.AddDeadLetter(ctx, second))
+
+	// Assert
+	assert.Equal(t, first.ID, second.ID)
+	stored, err := repo.GetDeadLetter(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.Attempts)
+}
+
+// Another message at a reused offset replaces the stored one and is no longer shown as redriven
+func TestAddDeadLetterReplacesMessageAtReusedOffset(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewDeadLettersRepo(newTestRepo(t).DB, 5*time.Second)
+	offset := time.Now().UnixNano()
+	old := &models.DeadLetter{Stage: "store", Error: "db is down", Topic: "orders-test", Offset: offset, Attempts: 3,
+		Key: "old", Payload: `{"order_uid":"old"}`, Headers: map[string]string{"source": "old"}}
+	reused := &models.DeadLetter{Stage: "decode", Error: "invalid json", Topic: "orders-test", Offset: offset, Attempts: 1,
+		Key: "new", Payload: "not json"}
+	t.Cleanup(func() {
+		_, _ = repo.DB.Exec(`DELETE FROM dead_letters WHERE topic = 'orders-test' AND "offset" = $1`, offset)
+	})
+	require.NoError(t, repo.AddDeadLetter(ctx, old))
+	require.NoError(t, repo.MarkRedriven(ctx, old.ID))
+
+	// Act
+	require.NoError(t, repo.AddDeadLetter(ctx, reused))
+
+	// Assert
+	assert.Equal(t, old.ID, reused.ID)
+	stored, err := repo.GetDeadLetter(ctx, reused.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "decode", stored.Stage)
+	assert.Equal(t, "new", stored.Key)
+	assert.Equal(t, "not json", stored.Payload)
+	assert.Nil(t, stored.Headers)
+	assert.Nil(t, stored.RedrivenAt)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+)
+
+// ErrOrderNotFound возвращается при удалении и восстановлении, если подходящего заказа нет
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrOrderDeleted возвращается UpsertOrder, если заказ удалён мягко: новую версию можно сохранить только после восстановления
+var ErrOrderDeleted = errors.New("order is deleted")
+
+const (
+	deleteOrderQuery      = "DELETE FROM orders WHERE order_uid = $1"
+	softDeleteOrderQuery  = "UPDATE orders SET deleted_at = now() WHERE order_uid = $1 AND deleted_at IS NULL"
+	softDeleteOrdersQuery = "UPDATE orders SET deleted_at = now() WHERE deleted_at IS NULL"
+	restoreOrderQuery     = "UPDATE orders SET deleted_at = NULL WHERE order_uid = $1 AND deleted_at IS NOT NULL"
+)
+
+// DeleteOrder безвозвратно удаляет заказ; доставка, платёж и товары удаляются каскадно.
+// Удалить можно и заказ, ранее удалённый мягко.
+func (o *OrdersRepo) DeleteOrder(ctx context.Context, orderUID string) error {
+	defer metrics.ObserveDBQuery("DeleteOrder", time.Now())
+
+	return o.execOne(ctx, deleteOrderQuery, orderUID)
+}
+
+// SoftDeleteOrder помечает заказ удалённым: он перестаёт возвращаться при чтении,
+// но остаётся в БД и может быть восстановлен RestoreOrder.
+func (o *OrdersRepo) SoftDeleteOrder(ctx context.Context, orderUID string) error {
+	defer metrics.ObserveDBQuery("SoftDeleteOrder", time.Now())
+
+	return o.execOne(ctx, softDeleteOrderQuery, orderUID)
+}
+
+// SoftDeleteOrders помечает удалёнными все заказы и возвращает их число.
+// Идентификаторы не возвращаются: на большой таблице их список не поместился бы в память.
+func (o *OrdersRepo) SoftDeleteOrders(ctx context.Context) (int64, error) {
+	defer metrics.ObserveDBQuery("SoftDeleteOrders", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	result, err := o.DB.ExecContext(ctx, softDeleteOrdersQuery)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orders: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted orders: %w", err)
+	}
+	return deleted, nil
+}
+
+// RestoreOrder возвращает мягко удалённый заказ.
+func (o *OrdersRepo) RestoreOrder(ctx context.Context, orderUID string) error {
+	defer metrics.ObserveDBQuery("RestoreOrder", time.Now())
+
+	return o.execOne(ctx, restoreOrderQuery, orderUID)
+}
+
+// execOne выполняет запрос, который должен затронуть ровно одну строку заказа.
+func (o *OrdersRepo) execOne(ctx context.Context, query, orderUID string) error {
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	result, err := o.DB.ExecContext(ctx, query, orderUID)
+	if err != nil {
+		return fmt.Errorf("order %s: %w", orderUID, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("order %s: %w", orderUID, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+	return nil
+}
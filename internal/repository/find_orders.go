@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/query"
+	"github.com/lib/pq"
+)
+
+const findOrdersFrom = ` FROM orders o
+    LEFT JOIN deliveries d ON d.order_uid = o.order_uid
+    LEFT JOIN payments p ON p.order_uid = o.order_uid`
+
+// Выражения сортировки; пустые значения приравниваются к минимальным, как и при сортировке в кэше
+var sortExpressions = map[query.SortField]string{
+	query.SortByDateCreated: "COALESCE(o.date_created, '0001-01-01T00:00:00Z'::timestamptz)",
+	query.SortByAmount:      "COALESCE(p.amount, 0)",
+	query.SortByOrderUID:    "o.order_uid",
+}
+
+// whereBuilder собирает условия WHERE с позиционными параметрами
+type whereBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// add добавляет условие; %d в условии заменяется номером параметра
+func (b *whereBuilder) add(condition string, args ...any) {
+	placeholders := make([]any, len(args))
+	for i, arg := range args {
+		b.args = append(b.args, arg)
+		placeholders[i] = len(b.args)
+	}
+	b.conditions = append(b.conditions, fmt.Sprintf(condition, placeholders...))
+}
+
+func (b *whereBuilder) String() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// FindOrders возвращает страницу заказов, подходящих под фильтр, в заданном порядке.
+// Следующая страница выбирается по курсору (keyset), поэтому глубина листания не влияет на скорость.
+func (o *OrdersRepo) FindOrders(ctx context.Context, params query.Params) (query.Page, error) {
+	defer metrics.ObserveDBQuery("FindOrders", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	where := buildFilter(params.Filter)
+
+	var total int
+	if err := o.DB.QueryRowContext(ctx, "SELECT count(*)"+findOrdersFrom+where.String(), where.args...).Scan(&total); err != nil {
+		return query.Page{}, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	sortExpr := sortExpressions[params.Sort]
+	if params.Cursor != nil {
+		if err := addCursor(&where, params, sortExpr); err != nil {
+			return query.Page{}, err
+		}
+	}
+
+	direction := "ASC"
+	if params.Desc {
+		direction = "DESC"
+	}
+	where.args = append(where.args, params.Limit+1)
+	pageQuery := fmt.Sprintf("SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, "+
+		"o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.version, o.status%s%s ORDER BY %s %s, o.order_uid %s LIMIT $%d",
+		findOrdersFrom, where.String(), sortExpr, direction, direction, len(where.args))
+
+	rows, err := o.DB.QueryContext(ctx, pageQuery, where.args...)
+	if err != nil {
+		return query.Page{}, fmt.Errorf("failed to find orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]models.Order, 0, params.Limit+1)
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return query.Page{}, fmt.Errorf("failed to scan order row: %w", err)
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return query.Page{}, fmt.Errorf("iteration over rows failed: %w", err)
+	}
+
+	page := query.Page{Orders: orders, Total: total}
+	if len(orders) > params.Limit {
+		page.Orders = orders[:params.Limit]
+	}
+	if len(page.Orders) > 0 {
+		if err := populateOrderDetails(ctx, o.DB, page.Orders); err != nil {
+			return query.Page{}, fmt.Errorf("failed to populate order details: %w", err)
+		}
+	}
+	if len(orders) > params.Limit {
+		page.NextCursor = query.CursorFor(page.Orders[len(page.Orders)-1], params.Sort).Encode()
+	}
+	return page, nil
+}
+
+func buildFilter(filter query.Filter) whereBuilder {
+	var where whereBuilder
+	where.add("o.deleted_at IS NULL")
+	for _, condition := range []struct {
+		sql   string
+		value string
+	}{
+		{"o.customer_id = $%d", filter.CustomerID},
+		{"o.track_number = $%d", filter.TrackNumber},
+		{"d.city = $%d", filter.City},
+		{"d.region = $%d", filter.Region},
+		{"p.currency = $%d", filter.Currency},
+		{"p.provider = $%d", filter.Provider},
+		{"o.locale = $%d", filter.Locale},
+	} {
+		if condition.value != "" {
+			where.add(condition.sql, condition.value)
+		}
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		where.add("o.status = ANY($%d)", pq.Array(statuses))
+	}
+	if filter.DateFrom != nil {
+		where.add("o.date_created >= $%d", filter.DateFrom.UTC())
+	}
+	if filter.DateTo != nil {
+		op := "<="
+		if filter.DateToExclusive {
+			op = "<"
+		}
+		where.add("o.date_created "+op+" $%d", filter.DateTo.UTC())
+	}
+	return where
+}
+
+// addCursor добавляет условие, отбирающее заказы после курсора
+func addCursor(where *whereBuilder, params query.Params, sortExpr string) error {
+	op := ">"
+	if params.Desc {
+		op = "<"
+	}
+
+	switch params.Sort {
+	case query.SortByDateCreated:
+		value, err := params.Cursor.DateValue()
+		if err != nil {
+			return err
+		}
+		where.add(fmt.Sprintf("(%s, o.order_uid) %s ($%%d::timestamptz, $%%d)", sortExpr, op), value.UTC(), params.Cursor.OrderUID)
+	case query.SortByAmount:
+		value, err := params.Cursor.AmountValue()
+		if err != nil {
+			return err
+		}
+		where.add(fmt.Sprintf("(%s, o.order_uid) %s ($%%d::numeric, $%%d)", sortExpr, op), value, params.Cursor.OrderUID)
+	default:
+		where.add(fmt.Sprintf("o.order_uid %s $%%d", op), params.Cursor.OrderUID)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+)
+
+// MemoryDeadLettersRepo карантин в памяти с той же семантикой, что и DeadLettersRepo:
+// одно исходное сообщение хранится один раз, страницы идут от самых свежих.
+type MemoryDeadLettersRepo struct {
+	mu      sync.RWMutex
+	letters []models.DeadLetter
+}
+
+// NewMemoryDeadLettersRepo создаёт пустой карантин в памяти
+func NewMemoryDeadLettersRepo() *MemoryDeadLettersRepo {
+	return &MemoryDeadLettersRepo{}
+}
+
+// AddDeadLetter сохраняет сообщение и заполняет ID и FailedAt, как DeadLettersRepo.AddDeadLetter
+func (m *MemoryDeadLettersRepo) AddDeadLetter(ctx context.Context, letter *models.DeadLetter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.letters {
+		stored := &m.letters[i]
+		if stored.Topic == letter.Topic && stored.Partition == letter.Partition && stored.Offset == letter.Offset {
+			letter.ID, letter.FailedAt, letter.RedrivenAt = stored.ID, time.Now().UTC(), nil
+			*stored = cloneDeadLetter(*letter)
+			return nil
+		}
+	}
+
+	letter.ID = int64(len(m.letters) + 1)
+	letter.FailedAt = time.Now().UTC()
+	m.letters = append(m.letters, cloneDeadLetter(*letter))
+	return nil
+}
+
+// GetDeadLetter возвращает сообщение по ID или nil, если его нет
+func (m *MemoryDeadLettersRepo) GetDeadLetter(ctx context.Context, id int64) (*models.DeadLetter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if id < 1 || id > int64(len(m.letters)) {
+		return nil, nil
+	}
+	letter := cloneDeadLetter(m.letters[id-1])
+	return &letter, nil
+}
+
+// GetDeadLetters возвращает страницу сообщений, начиная с самых свежих
+func (m *MemoryDeadLettersRepo) GetDeadLetters(ctx context.Context, limit, offset int) ([]models.DeadLetter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	letters := make([]models.DeadLetter, 0, limit)
+	for i := len(m.letters) - 1 - offset; i >= 0 && len(letters) < limit; i-- {
+		letters = append(letters, cloneDeadLetter(m.letters[i]))
+	}
+	return letters, nil
+}
+
+// MarkRedriven отмечает время повторной отправки сообщения в основной топик
+func (m *MemoryDeadLettersRepo) MarkRedriven(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id >= 1 && id <= int64(len(m.letters)) {
+		now := time.Now().UTC()
+		m.letters[id-1].RedrivenAt = &now
+	}
+	return nil
+}
+
+// cloneDeadLetter копирует сообщение вместе с заголовками
+func cloneDeadLetter(letter models.DeadLetter) models.DeadLetter {
+	letter.Headers = maps.Clone(letter.Headers)
+	if letter.RedrivenAt != nil {
+		redrivenAt := *letter.RedrivenAt
+		letter.RedrivenAt = &redrivenAt
+	}
+	return letter
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The same source message is stored once, and pages start from the newest letter
+func TestMemoryDeadLetters(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewMemoryDeadLettersRepo()
+	letter := func(offset int64, attempts int) *models.DeadLetter {
+		return &models.DeadLetter{Stage: "store", Error: "db is down", Topic: "orders", Offset: offset, Attempts: attempts}
+	}
+	first, retried, second := letter(1, 1), letter(1, 2), letter(2, 1)
+
+	// Act
+	require.NoError(t, repo.AddDeadLetter(ctx, first))
+	require.NoError(t, repo.AddDeadLetter(ctx, retried))
+	require.NoError(t, repo.AddDeadLetter(ctx, second))
+	require.NoError(t, repo.MarkRedriven(ctx, first.ID))
+
+	// Assert
+	assert.Equal(t, first.ID, retried.ID)
+	page, err := repo.GetDeadLetters(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, second.ID, page[0].ID)
+	assert.Equal(t, 2, page[1].Attempts)
+	assert.NotNil(t, page[1].RedrivenAt)
+
+	rest, err := repo.GetDeadLetters(ctx, 10, 1)
+	require.NoError(t, err)
+	assert.Len(t, rest, 1)
+	missing, err := repo.GetDeadLetter(ctx, 42)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+// Another message at a reused offset replaces the stored one and is no longer shown as redriven
+func TestMemoryDeadLetterReplacesMessageAtReusedOffset(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewMemoryDeadLettersRepo()
+	old := &models.DeadLetter{Stage: "store", Topic: "orders", Offset: 0, Key: "old", Payload: "old", Headers: map[string]string{"source": "old"}}
+	reused := &models.DeadLetter{Stage: "decode", Topic: "orders", Offset: 0, Key: "new", Payload: "new"}
+	require.NoError(t, repo.AddDeadLetter(ctx, old))
+	require.NoError(t, repo.MarkRedriven(ctx, old.ID))
+
+	// Act
+	require.NoError(t, repo.AddDeadLetter(ctx, reused))
+
+	// Assert
+	stored, err := repo.GetDeadLetter(ctx, old.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "decode", stored.Stage)
+	assert.Equal(t, "new", stored.Key)
+	assert.Equal(t, "new", stored.Payload)
+	assert.Nil(t, stored.Headers)
+	assert.Nil(t, stored.RedrivenAt)
+}
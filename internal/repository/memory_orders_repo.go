@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/query"
+)
+
+// MemoryOrdersRepo хранилище заказов в памяти с той же семантикой, что и OrdersRepo:
+// проверка дубликатов, версии и история, мягкое удаление и каскадное удаление истории.
+// Подходит для локального запуска без Postgres и для тестов.
+type MemoryOrdersRepo struct {
+	mu      sync.RWMutex
+	orders  map[string]*memoryOrder
+	history map[string][]models.OrderVersion
+}
+
+// memoryOrder сохранённый заказ, его история статусов и отметка о мягком удалении
+type memoryOrder struct {
+	order    models.Order
+	timeline []models.StatusChange
+	deleted  bool
+}
+
+// NewMemoryOrdersRepo создаёт пустое хранилище в памяти
+func NewMemoryOrdersRepo() *MemoryOrdersRepo {
+	return &MemoryOrdersRepo{
+		orders:  make(map[string]*memoryOrder),
+		history: make(map[string][]models.OrderVersion),
+	}
+}
+
+// AddOrder сохраняет новый заказ; заказ с тем же order_uid, в том числе удалённый мягко, даёт ErrOrderExists.
+func (m *MemoryOrdersRepo) AddOrder(ctx context.Context, order models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.orders[order.OrderUID]; ok {
+		return fmt.Errorf("order with order_uid %s: %w", order.OrderUID, ErrOrderExists)
+	}
+	m.orders[order.OrderUID] = newMemoryOrder(order)
+	return nil
+}
+
+// UpsertOrder сохраняет новый заказ или заменяет сохранённый более новой версией, как OrdersRepo.UpsertOrder.
+func (m *MemoryOrdersRepo) UpsertOrder(ctx context.Context, order models.Order) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.orders[order.OrderUID]
+	if !ok {
+		stored = newMemoryOrder(order)
+		m.orders[order.OrderUID] = stored
+		order = cloneOrder(stored.order)
+		return &order, nil
+	}
+
+	if stored.deleted {
+		return nil, fmt.Errorf("order %s: %w", order.OrderUID, ErrOrderDeleted)
+	}
+
+	current := stored.order.Version
+	switch {
+	case order.Version == 0:
+		order.Version = current + 1
+	case order.Version <= current:
+		return nil, fmt.Errorf("order %s version %d, stored version %d: %w",
+			order.OrderUID, order.Version, current, ErrStaleVersion)
+	}
+
+	// Новые версии идут первыми, как в GetOrderHistory
+	version := models.OrderVersion{Version: current, Order: stored.order, ReplacedAt: time.Now().UTC()}
+	m.history[order.OrderUID] = append([]models.OrderVersion{version}, m.history[order.OrderUID]...)
+	// Статус меняется только через ChangeOrderStatus
+	order.Status = stored.order.Status
+	stored.order = cloneOrder(order)
+	return &order, nil
+}
+
+// GetOrder возвращает заказ или nil, если его нет или он удалён
+func (m *MemoryOrdersRepo) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.orders[orderUID]
+	if !ok || stored.deleted {
+		return nil, nil
+	}
+	order := cloneOrder(stored.order)
+	return &order, nil
+}
+
+// GetOrders возвращает все неудалённые заказы в порядке order_uid
+func (m *MemoryOrdersRepo) GetOrders(ctx context.Context) ([]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.activeOrders(), nil
+}
+
+// StreamOrders передаёт неудалённые заказы в fn пачками по batchSize в порядке order_uid
+func (m *MemoryOrdersRepo) StreamOrders(ctx context.Context, batchSize int, fn func(batch []models.Order) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	orders := m.activeOrders()
+	for start := 0; start < len(orders); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(orders[start:min(start+batchSize, len(orders))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindOrders отбирает неудалённые заказы по фильтру и разбивает их на страницы так же, как OrdersRepo.FindOrders
+func (m *MemoryOrdersRepo) FindOrders(ctx context.Context, params query.Params) (query.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return query.Page{}, err
+	}
+	return query.Apply(m.activeOrders(), params)
+}
+
+// DeleteOrder безвозвратно удаляет заказ вместе с историей; удалить можно и заказ, удалённый мягко
+func (m *MemoryOrdersRepo) DeleteOrder(ctx context.Context, orderUID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.orders[orderUID]; !ok {
+		return fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+	delete(m.orders, orderUID)
+	delete(m.history, orderUID)
+	return nil
+}
+
+// SoftDeleteOrder помечает заказ удалённым
+func (m *MemoryOrdersRepo) SoftDeleteOrder(ctx context.Context, orderUID string) error {
+	return m.setDeleted(ctx, orderUID, true)
+}
+
+// SoftDeleteOrders помечает удалёнными все заказы и возвращает их число
+func (m *MemoryOrdersRepo) SoftDeleteOrders(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for _, stored := range m.orders {
+		if !stored.deleted {
+			stored.deleted = true
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// RestoreOrder возвращает мягко удалённый заказ
+func (m *MemoryOrdersRepo) RestoreOrder(ctx context.Context, orderUID string) error {
+	return m.setDeleted(ctx, orderUID, false)
+}
+
+// GetOrderHistory возвращает заменённые версии заказа, начиная с последней.
+// Для неизвестного или удалённого заказа возвращает ErrOrderNotFound.
+func (m *MemoryOrdersRepo) GetOrderHistory(ctx context.Context, orderUID string) ([]models.OrderVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if stored, ok := m.orders[orderUID]; !ok || stored.deleted {
+		return nil, fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+
+	history := make([]models.OrderVersion, len(m.history[orderUID]))
+	for i, version := range m.history[orderUID] {
+		version.Order = cloneOrder(version.Order)
+		history[i] = version
+	}
+	return history, nil
+}
+
+// ChangeOrderStatus переводит заказ в статус change.Status так же, как OrdersRepo.ChangeOrderStatus
+func (m *MemoryOrdersRepo) ChangeOrderStatus(ctx context.Context, orderUID string, change models.StatusChange) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.orders[orderUID]
+	if !ok || stored.deleted {
+		return nil, fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+	if change.Status != stored.order.Status {
+		if err := models.ValidateTransition(stored.order.Status, change.Status); err != nil {
+			return nil, fmt.Errorf("order %s: %w", orderUID, err)
+		}
+		if change.ChangedAt.IsZero() {
+			change.ChangedAt = time.Now().UTC()
+		}
+		stored.order.Status = change.Status
+		stored.timeline = append(stored.timeline, change)
+	}
+	order := cloneOrder(stored.order)
+	return &order, nil
+}
+
+// GetOrderTimeline возвращает переходы заказа между статусами, начиная с создания.
+// Для неизвестного или удалённого заказа возвращает ErrOrderNotFound.
+func (m *MemoryOrdersRepo) GetOrderTimeline(ctx context.Context, orderUID string) ([]models.StatusChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.orders[orderUID]
+	if !ok || stored.deleted {
+		return nil, fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+	return slices.Clone(stored.timeline), nil
+}
+
+// setDeleted меняет отметку об удалении; заказ, который уже в нужном состоянии, даёт ErrOrderNotFound
+func (m *MemoryOrdersRepo) setDeleted(ctx context.Context, orderUID string, deleted bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.orders[orderUID]
+	if !ok || stored.deleted == deleted {
+		return fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+	stored.deleted = deleted
+	return nil
+}
+
+// newMemoryOrder готовит новый заказ к сохранению: версия не меньше 1, статус created и первый переход в истории статусов
+func newMemoryOrder(order models.Order) *memoryOrder {
+	order.Version = max(order.Version, 1)
+	order.Status = models.StatusCreated
+	created := models.StatusChange{Status: models.StatusCreated, ChangedAt: order.DateCreated}
+	if created.ChangedAt.IsZero() {
+		created.ChangedAt = time.Now().UTC()
+	}
+	return &memoryOrder{order: cloneOrder(order), timeline: []models.StatusChange{created}}
+}
+
+// activeOrders возвращает копии неудалённых заказов в порядке order_uid
+func (m *MemoryOrdersRepo) activeOrders() []models.Order {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := make([]models.Order, 0, len(m.orders))
+	for _, stored := range m.orders {
+		if !stored.deleted {
+			orders = append(orders, cloneOrder(stored.order))
+		}
+	}
+	slices.SortFunc(orders, func(a, b models.Order) int {
+		return strings.Compare(a.OrderUID, b.OrderUID)
+	})
+	return orders
+}
+
+// cloneOrder копирует заказ вместе с товарами, чтобы вызывающий код не мог изменить сохранённый заказ
+func cloneOrder(order models.Order) models.Order {
+	order.Items = slices.Clone(order.Items)
+	return order
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/order_gen"
+	"github.com/ZnNr/WB-test-L0/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A second order with the same UID is rejected, even after a soft delete
+func TestMemoryAddOrderRejectsDuplicates(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+	require.NoError(t, repo.AddOrder(ctx, order))
+
+	// Act
+	duplicate := repo.AddOrder(ctx, order)
+	require.NoError(t, repo.SoftDeleteOrder(ctx, order.OrderUID))
+	deletedDuplicate := repo.AddOrder(ctx, order)
+
+	// Assert
+	assert.ErrorIs(t, duplicate, ErrOrderExists)
+	assert.ErrorIs(t, deletedDuplicate, ErrOrderExists)
+}
+
+// Upserts bump versions, keep replaced versions in history and reject stale ones
+func TestMemoryUpsertOrderVersions(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+
+	// Act
+	first, err := repo.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+	order.Items[0].Name = "renamed"
+	second, err := repo.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+	order.Version = 2
+	_, staleErr := repo.UpsertOrder(ctx, order)
+
+	// Assert
+	assert.Equal(t, int64(1), first.Version)
+	assert.Equal(t, int64(2), second.Version)
+	assert.ErrorIs(t, staleErr, ErrStaleVersion)
+
+	stored, err := repo.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", stored.Items[0].Name)
+
+	history, err := repo.GetOrderHistory(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, int64(1), history[0].Version)
+	assert.NotEqual(t, "renamed", history[0].Order.Items[0].Name)
+}
+
+// Soft-deleted orders are hidden until restored; a hard delete also drops the history
+func TestMemoryDeleteAndRestore(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+	_, err := repo.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+	_, err = repo.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+
+	// Act & Assert
+	require.NoError(t, repo.SoftDeleteOrder(ctx, order.OrderUID))
+	assert.ErrorIs(t, repo.SoftDeleteOrder(ctx, order.OrderUID), ErrOrderNotFound)
+	stored, err := repo.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	_, err = repo.GetOrderHistory(ctx, order.OrderUID)
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+
+	require.NoError(t, repo.RestoreOrder(ctx, order.OrderUID))
+	assert.ErrorIs(t, repo.RestoreOrder(ctx, order.OrderUID), ErrOrderNotFound)
+
+	require.NoError(t, repo.DeleteOrder(ctx, order.OrderUID))
+	assert.ErrorIs(t, repo.DeleteOrder(ctx, order.OrderUID), ErrOrderNotFound)
+	_, err = repo.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+	history, err := repo.GetOrderHistory(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.Empty(t, history, "History of a purged order must not reappear")
+}
+
+// A new version of a soft-deleted order is rejected until the order is restored
+func TestMemoryUpsertOrderRejectsSoftDeletedOrder(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+	require.NoError(t, repo.AddOrder(ctx, order))
+	require.NoError(t, repo.SoftDeleteOrder(ctx, order.OrderUID))
+
+	// Act
+	order.CustomerID = "customer-2"
+	rejected, err := repo.UpsertOrder(ctx, order)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrOrderDeleted)
+	assert.Nil(t, rejected)
+	stored, err := repo.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	require.NoError(t, repo.RestoreOrder(ctx, order.OrderUID))
+	updated, err := repo.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+	assert.Equal(t, "customer-2", updated.CustomerID)
+}
+
+// Streaming, listing and searching skip deleted orders and honour filters
+func TestMemoryReadsSkipDeletedOrders(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewMemoryOrdersRepo()
+	var orders []models.Order
+	for i := 0; i < 5; i++ {
+		order := order_gen.GenerateOrder()
+		order.CustomerID = "c1"
+		if i%2 == 1 {
+			order.CustomerID = "c2"
+		}
+		require.NoError(t, repo.AddOrder(ctx, order))
+		orders = append(orders, order)
+	}
+	require.NoError(t, repo.SoftDeleteOrder(ctx, orders[0].OrderUID))
+
+	// Act
+	var batches []int
+	err := repo.StreamOrders(ctx, 2, func(batch []models.Order) error {
+		batches = append(batches, len(batch))
+		return nil
+	})
+	require.NoError(t, err)
+	all, err := repo.GetOrders(ctx)
+	require.NoError(t, err)
+	params, err := query.Parse(url.Values{"customer_id": {"c1"}})
+	require.NoError(t, err)
+	page, err := repo.FindOrders(ctx, params)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, []int{2, 2}, batches)
+	assert.Len(t, all, 4)
+	assert.IsIncreasing(t, orderUIDs(all))
+	assert.Equal(t, 2, page.Total)
+	for _, order := range page.Orders {
+		assert.Equal(t, "c1", order.CustomerID)
+	}
+}
+
+// Callers cannot change stored orders through returned values
+func TestMemoryReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+	require.NoError(t, repo.AddOrder(ctx, order))
+
+	order.Items[0].Name = "changed by caller"
+	stored, err := repo.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	stored.Items[0].Name = "changed by reader"
+
+	again, err := repo.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.NotEqual(t, "changed by caller", again.Items[0].Name)
+	assert.NotEqual(t, "changed by reader", again.Items[0].Name)
+}
+
+func orderUIDs(orders []models.Order) []string {
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		uids[i] = order.OrderUID
+	}
+	return uids
+}
+
+// Status changes follow the transition table, repeats are no-ops and upserts keep the status
+func TestMemoryChangeOrderStatus(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewMemoryOrdersRepo()
+	order := order_gen.GenerateOrder()
+	order.Status = models.StatusDelivered
+	require.NoError(t, repo.AddOrder(ctx, order))
+	paidAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Act
+	paid, err := repo.ChangeOrderStatus(ctx, order.OrderUID, models.StatusChange{Status: models.StatusPaid, ChangedAt: paidAt})
+	require.NoError(t, err)
+	_, repeatErr := repo.ChangeOrderStatus(ctx, order.OrderUID, models.StatusChange{Status: models.StatusPaid})
+	_, invalidErr := repo.ChangeOrderStatus(ctx, order.OrderUID, models.StatusChange{Status: models.StatusDelivered})
+	_, unknownErr := repo.ChangeOrderStatus(ctx, "unknown", models.StatusChange{Status: models.StatusPaid})
+	upserted, err := repo.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, models.StatusPaid, paid.Status)
+	assert.NoError(t, repeatErr)
+	assert.ErrorIs(t, invalidErr, models.ErrInvalidTransition)
+	assert.ErrorIs(t, unknownErr, ErrOrderNotFound)
+	assert.Equal(t, models.StatusPaid, upserted.Status)
+
+	timeline, err := repo.GetOrderTimeline(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.Len(t, timeline, 2)
+	assert.Equal(t, models.StatusCreated, timeline[0].Status)
+	assert.True(t, order.DateCreated.Equal(timeline[0].ChangedAt))
+	assert.Equal(t, models.StatusChange{Status: models.StatusPaid, ChangedAt: paidAt}, timeline[1])
+
+	require.NoError(t, repo.SoftDeleteOrder(ctx, order.OrderUID))
+	_, err = repo.GetOrderTimeline(ctx, order.OrderUID)
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+}
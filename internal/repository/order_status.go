@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/repository/database"
+)
+
+const (
+	lockOrderStatusQuery   = "SELECT status FROM orders WHERE order_uid = $1 AND deleted_at IS NULL FOR UPDATE"
+	updateOrderStatusQuery = "UPDATE orders SET status = $2 WHERE order_uid = $1"
+	addStatusChangeQuery   = "INSERT INTO order_status_history (order_uid, status, changed_at, reason) VALUES ($1, $2, $3, $4)"
+	getTimelineQuery       = "SELECT status, changed_at, COALESCE(reason, '') FROM order_status_history WHERE order_uid = $1 ORDER BY id"
+)
+
+// ChangeOrderStatus переводит заказ в статус change.Status и добавляет переход в историю статусов.
+// Переход в текущий статус ничего не меняет, поэтому повторное событие безопасно.
+// Переход, не разрешённый таблицей переходов, отклоняется с models.ErrInvalidTransition,
+// неизвестный или удалённый заказ — с ErrOrderNotFound. Возвращает заказ после изменения.
+func (o *OrdersRepo) ChangeOrderStatus(ctx context.Context, orderUID string, change models.StatusChange) (*models.Order, error) {
+	defer metrics.ObserveDBQuery("ChangeOrderStatus", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now().UTC()
+	}
+
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		// Блокируем строку заказа, чтобы параллельные переходы проверялись по очереди
+		var current models.OrderStatus
+		err := tx.QueryRowContext(ctx, lockOrderStatusQuery, orderUID).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock order: %w", err)
+		}
+
+		if change.Status == current {
+			return nil
+		}
+		if err := models.ValidateTransition(current, change.Status); err != nil {
+			return fmt.Errorf("order %s: %w", orderUID, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, updateOrderStatusQuery, orderUID, change.Status); err != nil {
+			return fmt.Errorf("failed to update order status: %w", err)
+		}
+		return addStatusChange(ctx, tx, orderUID, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := o.GetOrder(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		// Заказ удалили сразу после смены статуса
+		return nil, fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+	return order, nil
+}
+
+// GetOrderTimeline возвращает переходы заказа между статусами, начиная с создания.
+// Для неизвестного или удалённого заказа возвращает ErrOrderNotFound.
+func (o *OrdersRepo) GetOrderTimeline(ctx context.Context, orderUID string) ([]models.StatusChange, error) {
+	defer metrics.ObserveDBQuery("GetOrderTimeline", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	var exists bool
+	if err := o.DB.QueryRowContext(ctx, activeOrderExistsQuery, orderUID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check if order exists: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+
+	rows, err := o.DB.QueryContext(ctx, getTimelineQuery, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order timeline: %w", err)
+	}
+	defer rows.Close()
+
+	timeline := []models.StatusChange{}
+	for rows.Next() {
+		var change models.StatusChange
+		if err := rows.Scan(&change.Status, &change.ChangedAt, &change.Reason); err != nil {
+			return nil, fmt.Errorf("failed to scan order timeline row: %w", err)
+		}
+		change.ChangedAt = change.ChangedAt.UTC()
+		timeline = append(timeline, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iteration over rows failed: %w", err)
+	}
+	return timeline, nil
+}
+
+// addStatusChange добавляет переход в историю статусов заказа
+func addStatusChange(ctx context.Context, db database.Executor, orderUID string, change models.StatusChange) error {
+	var reason sql.NullString
+	if change.Reason != "" {
+		reason = sql.NullString{String: change.Reason, Valid: true}
+	}
+	if _, err := db.ExecContext(ctx, addStatusChangeQuery, orderUID, change.Status, change.ChangedAt, reason); err != nil {
+		return fmt.Errorf("failed to add status change: %w", err)
+	}
+	return nil
+}
@@ -1,24 +1,36 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
 	"github.com/ZnNr/WB-test-L0/internal/models"
 	"github.com/ZnNr/WB-test-L0/internal/repository/config"
 	"github.com/ZnNr/WB-test-L0/internal/repository/database"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const (
-	addOrderQuery     = `INSERT INTO orders("order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard") VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	getOrderQuery     = "SELECT order_uid, track_number, entry, delivery, payment, items, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders WHERE order_uid = $1"
-	getAllOrdersQuery = "SELECT * FROM orders"
+	addOrderQuery       = `INSERT INTO orders("order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "version") VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	orderColumns        = "order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, version, status"
+	getOrderQuery       = "SELECT " + orderColumns + " FROM orders WHERE order_uid = $1 AND deleted_at IS NULL"
+	getOrdersBatchQuery = "SELECT " + orderColumns + " FROM orders WHERE order_uid > $1 AND deleted_at IS NULL ORDER BY order_uid LIMIT $2"
 )
 
+// DefaultBatchSize размер пачки заказов, загружаемой GetOrders за один проход
+const DefaultBatchSize = 1000
+
+// ErrOrderExists возвращается AddOrder, если заказ с таким order_uid уже сохранён
+var ErrOrderExists = errors.New("order already exists")
+
 type OrdersRepo struct {
 	DB *sql.DB
+	// Timeout предельное время одной операции; при потоковом чтении — одной пачки. Ноль не ограничивает.
+	Timeout time.Duration
 }
 
 func New(cfg *config.Config) (*OrdersRepo, error) {
@@ -35,66 +47,129 @@ func New(cfg *config.Config) (*OrdersRepo, error) {
 		return nil, err
 	}
 
-	return &OrdersRepo{DB: db}, nil
+	return &OrdersRepo{DB: db, Timeout: cfg.DB.QueryTimeout}, nil
+}
+
+func (o *OrdersRepo) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	defer metrics.ObserveDBQuery("OrderExists", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	return orderExists(ctx, o.DB, orderUID)
 }
 
-func (o *OrdersRepo) OrderExists(orderUID string) (bool, error) {
+func orderExists(ctx context.Context, db database.Executor, orderUID string) (bool, error) {
 	var exists bool
-	err := o.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", orderUID).Scan(&exists)
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", orderUID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 	return exists, nil
 }
-func (o *OrdersRepo) AddOrder(order models.Order) error {
-	// существует ли заказ?
-	exists, err := o.OrderExists(order.OrderUID)
-	if err != nil {
-		return fmt.Errorf("failed to check if order exists: %w", err)
-	}
 
-	if exists {
-		return fmt.Errorf("order with order_uid %s already exists", order.OrderUID)
-	}
+// AddOrder сохраняет заказ вместе с платежом, товарами и доставкой в одной транзакции.
+// При ошибке на любом шаге транзакция откатывается, и в БД не остаётся частично записанного заказа.
+func (o *OrdersRepo) AddOrder(ctx context.Context, order models.Order) error {
+	defer metrics.ObserveDBQuery("AddOrder", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	return o.withTx(ctx, func(tx *sql.Tx) error {
+		// существует ли заказ?
+		exists, err := orderExists(ctx, tx, order.OrderUID)
+		if err != nil {
+			return fmt.Errorf("failed to check if order exists: %w", err)
+		}
+
+		if exists {
+			return fmt.Errorf("order with order_uid %s: %w", order.OrderUID, ErrOrderExists)
+		}
 
+		return insertOrder(ctx, tx, order)
+	})
+}
+
+// insertOrder вставляет новый заказ вместе с платежом, товарами и доставкой.
+// Заказ без версии сохраняется как версия 1; статус нового заказа — created.
+func insertOrder(ctx context.Context, tx *sql.Tx, order models.Order) error {
 	// Вставляем заказ в базу данных
-	_, err = o.DB.Exec(addOrderQuery, order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
+	_, err := tx.ExecContext(ctx, addOrderQuery, order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
 		order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey,
-		order.SmID, order.DateCreated, order.OofShard)
+		order.SmID, order.DateCreated, order.OofShard, max(order.Version, 1))
 	if err != nil {
+		if isUniqueViolation(err) {
+			// Заказ успели вставить параллельно
+			return fmt.Errorf("order with order_uid %s: %w", order.OrderUID, ErrOrderExists)
+		}
 		return fmt.Errorf("failed to insert order: %w", err)
 	}
 
 	// Проверка существования платежа и добавление при необходимости.
-	if err := o.processPayment(order); err != nil {
+	if err := processPayment(ctx, tx, order); err != nil {
 		return fmt.Errorf("failed to process payment: %w", err)
 	}
 
 	// Добавление предметов заказа
-	if err := database.AddItems(o.DB, order.Items, order.OrderUID); err != nil {
+	if err := database.AddItems(ctx, tx, order.Items, order.OrderUID); err != nil {
 		return fmt.Errorf("failed to insert items: %w", err)
 	}
 
 	// Добавление доставки
-	statusMessage, err := database.AddDelivery(o.DB, order.Delivery, order.OrderUID)
-	if err != nil {
+	if _, err := database.AddDelivery(ctx, tx, order.Delivery, order.OrderUID); err != nil {
 		return fmt.Errorf("failed to insert delivery: %w", err)
 	}
 
-	fmt.Println(statusMessage)
+	// История статусов начинается с создания заказа
+	created := models.StatusChange{Status: models.StatusCreated, ChangedAt: order.DateCreated}
+	if created.ChangedAt.IsZero() {
+		created.ChangedAt = time.Now().UTC()
+	}
+	if err := addStatusChange(ctx, tx, order.OrderUID, created); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// withTx выполняет fn в транзакции: фиксирует её, если fn завершилась успешно, и откатывает в противном случае.
+func (o *OrdersRepo) withTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := o.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to rollback transaction: %w", rbErr))
+			}
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation проверяет, что ошибка Postgres вызвана нарушением уникальности.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
 // processPayment проверяет существование платежа и добавляет новый, если его нет.
-func (o *OrdersRepo) processPayment(order models.Order) error {
-	exists, err := database.PaymentExists(o.DB, order.OrderUID)
+func processPayment(ctx context.Context, db database.Executor, order models.Order) error {
+	exists, err := database.PaymentExists(ctx, db, order.OrderUID)
 	if err != nil {
 		return fmt.Errorf("failed to check if payment exists: %w", err)
 	}
 
 	if !exists {
-		if err := database.AddPayment(o.DB, order.Payment, order.OrderUID); err != nil {
+		if err := database.AddPayment(ctx, db, order.Payment, order.OrderUID); err != nil {
 			return fmt.Errorf("failed to insert payment: %w", err)
 		}
 	}
@@ -102,71 +177,154 @@ func (o *OrdersRepo) processPayment(order models.Order) error {
 	return nil
 }
 
-func (o *OrdersRepo) GetOrder(orderUID string) (*models.Order, error) {
-	var order models.Order
-
-	if err := o.DB.QueryRow(getOrderQuery, orderUID).Scan(&order.OrderUID,
-		&order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
-		&order.CustomerID, &order.DeliveryService, &order.Shardkey, &order.SmID,
-		&order.DateCreated, &order.OofShard); err != nil {
+func (o *OrdersRepo) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	defer metrics.ObserveDBQuery("GetOrder", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
 
+	order, err := scanOrder(o.DB.QueryRowContext(ctx, getOrderQuery, orderUID))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	if err := populateOrderDetails(o.DB, &order); err != nil { // Изменено здесь
+	orders := []models.Order{*order}
+	if err := populateOrderDetails(ctx, o.DB, orders); err != nil {
 		return nil, fmt.Errorf("failed to populate order details: %w", err)
 	}
 
-	return &order, nil
+	return &orders[0], nil
 }
 
-func populateOrderDetails(db *sql.DB, order *models.Order) error {
-	delivery, err := database.GetDelivery(db, order.OrderUID)
-	if err != nil {
-		return err
-	}
-	order.Delivery = *delivery
+// GetOrders загружает все заказы пачками по DefaultBatchSize.
+func (o *OrdersRepo) GetOrders(ctx context.Context) ([]models.Order, error) {
+	defer metrics.ObserveDBQuery("GetOrders", time.Now())
 
-	payment, err := database.GetPayment(db, order.OrderUID)
+	var orders []models.Order
+	err := o.StreamOrders(ctx, DefaultBatchSize, func(batch []models.Order) error {
+		orders = append(orders, batch...)
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	order.Payment = *payment
+	return orders, nil
+}
 
-	items, err := database.GetItems(db, order.OrderUID)
-	if err != nil {
-		return err
+// StreamOrders загружает заказы пачками по batchSize в порядке order_uid и передаёт каждую пачку в fn.
+// На пачку приходится постоянное число запросов: заказы, доставки, платежи и товары,
+// а в памяти одновременно держится только одна пачка.
+func (o *OrdersRepo) StreamOrders(ctx context.Context, batchSize int, fn func(batch []models.Order) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
 	}
-	order.Items = items
 
-	return nil
+	lastUID := ""
+	for {
+		batch, err := o.getOrdersBatch(ctx, lastUID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(batch) < batchSize {
+			return nil
+		}
+		lastUID = batch[len(batch)-1].OrderUID
+	}
 }
 
-func (o *OrdersRepo) GetOrders() ([]models.Order, error) {
-	rows, err := o.DB.Query(getAllOrdersQuery)
+// getOrdersBatch загружает до limit заказов с order_uid больше afterUID вместе с доставкой, платежом и товарами.
+func (o *OrdersRepo) getOrdersBatch(ctx context.Context, afterUID string, limit int) ([]models.Order, error) {
+	defer metrics.ObserveDBQuery("getOrdersBatch", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	rows, err := o.DB.QueryContext(ctx, getOrdersBatchQuery, afterUID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
 	defer rows.Close()
 
-	var orders []models.Order
+	orders := make([]models.Order, 0, limit)
 	for rows.Next() {
-		var order models.Order
-		if err := rows.Scan(&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
-			&order.InternalSignature, &order.CustomerID, &order.DeliveryService, &order.Shardkey,
-			&order.SmID, &order.DateCreated, &order.OofShard); err != nil {
+		order, err := scanOrder(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan order row: %w", err)
 		}
+		orders = append(orders, *order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iteration over rows failed: %w", err)
+	}
+	rows.Close()
 
-		if err := populateOrderDetails(o.DB, &order); err != nil { // Изменено здесь
-			return nil, fmt.Errorf("failed to get details for order %s: %w", order.OrderUID, err)
+	if len(orders) > 0 {
+		if err := populateOrderDetails(ctx, o.DB, orders); err != nil {
+			return nil, fmt.Errorf("failed to get order details: %w", err)
 		}
+	}
+	return orders, nil
+}
 
-		orders = append(orders, order)
+// withTimeout ограничивает операцию с БД временем timeout; нулевой timeout её не ограничивает
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, timeout)
+}
 
-	return orders, nil
+// populateOrderDetails заполняет доставку, платёж и товары для пачки заказов тремя запросами.
+func populateOrderDetails(ctx context.Context, db database.Executor, orders []models.Order) error {
+	orderUIDs := make([]string, len(orders))
+	for i := range orders {
+		orderUIDs[i] = orders[i].OrderUID
+	}
+
+	deliveries, err := database.GetDeliveriesByOrders(ctx, db, orderUIDs)
+	if err != nil {
+		return err
+	}
+	payments, err := database.GetPaymentsByOrders(ctx, db, orderUIDs)
+	if err != nil {
+		return err
+	}
+	items, err := database.GetItemsByOrders(ctx, db, orderUIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range orders {
+		orders[i].Delivery = deliveries[orders[i].OrderUID]
+		orders[i].Payment = payments[orders[i].OrderUID]
+		orders[i].Items = items[orders[i].OrderUID]
+	}
+	return nil
+}
+
+func scanOrder(row rowScanner) (*models.Order, error) {
+	var (
+		order   models.Order
+		created sql.NullTime
+	)
+	if err := row.Scan(&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
+		&order.InternalSignature, &order.CustomerID, &order.DeliveryService, &order.Shardkey,
+		&order.SmID, &created, &order.OofShard, &order.Version, &order.Status); err != nil {
+		return nil, err
+	}
+	// У старых заказов дата может отсутствовать
+	if created.Valid {
+		order.DateCreated = created.Time.UTC()
+	}
+	return &order, nil
 }
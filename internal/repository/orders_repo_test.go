@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/order_gen"
+	"github.com/ZnNr/WB-test-L0/internal/query"
+	"github.com/ZnNr/WB-test-L0/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testDSNEnv names the variable with the connection string of a disposable test database
+const testDSNEnv = "TEST_DATABASE_DSN"
+
+// newTestRepo connects to the test database and applies all migrations.
+// Tests are skipped when no test database is configured.
+func newTestRepo(t *testing.T) *OrdersRepo {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migration.New(db, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(context.Background()))
+
+	return &OrdersRepo{DB: db, Timeout: 5 * time.Second}
+}
+
+// addTestOrder stores an order and removes it when the test ends
+func addTestOrder(t *testing.T, repo *OrdersRepo, order models.Order) {
+	t.Helper()
+	require.NoError(t, repo.AddOrder(context.Background(), order))
+	t.Cleanup(func() { _ = repo.DeleteOrder(context.Background(), order.OrderUID) })
+}
+
+func chrtIDs(items []models.Item) []int {
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ChrtID
+	}
+	return ids
+}
+
+// Two orders containing the same product size both keep their items
+func TestOrdersSharingChrtIDKeepTheirItems(t *testing.T) {
+	// Arrange
+	repo := newTestRepo(t)
+	first := order_gen.GenerateOrder()
+	second := order_gen.GenerateOrder()
+	second.Items[0].ChrtID = first.Items[0].ChrtID
+
+	// Act
+	addTestOrder(t, repo, first)
+	addTestOrder(t, repo, second)
+
+	// Assert
+	for _, order := range []models.Order{first, second} {
+		stored, err := repo.GetOrder(context.Background(), order.OrderUID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, order.Items, stored.Items)
+	}
+}
+
+// Repeated lines of one product within an order are stored as separate lines in order
+func TestOrderKeepsRepeatedChrtIDLines(t *testing.T) {
+	// Arrange
+	repo := newTestRepo(t)
+	order := order_gen.GenerateOrder()
+	line := order.Items[0]
+	line.Rid = line.Rid + "-2"
+	order.Items = append(order.Items, line)
+
+	// Act
+	addTestOrder(t, repo, order)
+
+	// Assert
+	stored, err := repo.GetOrder(context.Background(), order.OrderUID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, chrtIDs(order.Items), chrtIDs(stored.Items))
+	assert.Equal(t, order.Items, stored.Items)
+}
+
+// Replacing an order's items does not touch another order's items with the same chrt_id
+func TestUpsertOrderKeepsItemsOfOtherOrders(t *testing.T) {
+	// Arrange
+	repo := newTestRepo(t)
+	first := order_gen.GenerateOrder()
+	second := order_gen.GenerateOrder()
+	second.Items[0].ChrtID = first.Items[0].ChrtID
+	addTestOrder(t, repo, first)
+	addTestOrder(t, repo, second)
+
+	// Act
+	second.Items[0].Name = "renamed"
+	_, err := repo.UpsertOrder(context.Background(), second)
+
+	// Assert
+	require.NoError(t, err)
+	stored, err := repo.GetOrders(context.Background())
+	require.NoError(t, err)
+	byUID := make(map[string]models.Order, len(stored))
+	for _, order := range stored {
+		byUID[order.OrderUID] = order
+	}
+	assert.Equal(t, first.Items, byUID[first.OrderUID].Items)
+	assert.Equal(t, "renamed", byUID[second.OrderUID].Items[0].Name)
+}
+
+// A new version of a soft-deleted order is rejected and the order stays deleted
+func TestUpsertOrderRejectsSoftDeletedOrder(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	order := order_gen.GenerateOrder()
+	addTestOrder(t, repo, order)
+	require.NoError(t, repo.SoftDeleteOrder(ctx, order.OrderUID))
+
+	// Act
+	order.CustomerID = "customer-2"
+	_, err := repo.UpsertOrder(ctx, order)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrOrderDeleted)
+	stored, err := repo.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	require.NoError(t, repo.RestoreOrder(ctx, order.OrderUID))
+	updated, err := repo.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+}
+
+// A date-only date_to keeps orders created later that day in the result
+func TestFindOrdersDateToIncludesWholeDay(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	customerID := order_gen.GenerateOrder().OrderUID
+	for _, created := range []string{"2024-01-05T12:00:00Z", "2024-01-06T00:00:00Z"} {
+		order := order_gen.GenerateOrder()
+		order.CustomerID = customerID
+		order.DateCreated, _ = models.ParseDate(created)
+		addTestOrder(t, repo, order)
+	}
+	params, err := query.Parse(url.Values{"customer_id": {customerID}, "date_to": {"2024-01-05"}})
+	require.NoError(t, err)
+
+	// Act
+	page, err := repo.FindOrders(ctx, params)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 1)
+	assert.Equal(t, "2024-01-05T12:00:00Z", page.Orders[0].DateCreated.UTC().Format(time.RFC3339))
+}
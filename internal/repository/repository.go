@@ -1,9 +1,39 @@
 package repository
 
-import "github.com/ZnNr/WB-test-L0/internal/models"
+import (
+	"context"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/query"
+)
 
 type Orders interface {
-	AddOrder(order models.Order) error
-	GetOrder(OrderUID string) (*models.Order, error)
-	GetOrders() ([]models.Order, error)
+	AddOrder(ctx context.Context, order models.Order) error
+	UpsertOrder(ctx context.Context, order models.Order) (*models.Order, error)
+	GetOrder(ctx context.Context, OrderUID string) (*models.Order, error)
+	GetOrders(ctx context.Context) ([]models.Order, error)
+	StreamOrders(ctx context.Context, batchSize int, fn func(batch []models.Order) error) error
+	FindOrders(ctx context.Context, params query.Params) (query.Page, error)
+	DeleteOrder(ctx context.Context, orderUID string) error
+	SoftDeleteOrder(ctx context.Context, orderUID string) error
+	SoftDeleteOrders(ctx context.Context) (int64, error)
+	RestoreOrder(ctx context.Context, orderUID string) error
+	GetOrderHistory(ctx context.Context, orderUID string) ([]models.OrderVersion, error)
+	ChangeOrderStatus(ctx context.Context, orderUID string, change models.StatusChange) (*models.Order, error)
+	GetOrderTimeline(ctx context.Context, orderUID string) ([]models.StatusChange, error)
+}
+
+// DeadLetters хранилище сообщений, помещённых в карантин
+type DeadLetters interface {
+	AddDeadLetter(ctx context.Context, letter *models.DeadLetter) error
+	GetDeadLetter(ctx context.Context, id int64) (*models.DeadLetter, error)
+	GetDeadLetters(ctx context.Context, limit, offset int) ([]models.DeadLetter, error)
+	MarkRedriven(ctx context.Context, id int64) error
 }
+
+var (
+	_ Orders      = (*OrdersRepo)(nil)
+	_ Orders      = (*MemoryOrdersRepo)(nil)
+	_ DeadLetters = (*DeadLettersRepo)(nil)
+	_ DeadLetters = (*MemoryDeadLettersRepo)(nil)
+)
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ZnNr/WB-test-L0/internal/repository/config"
+)
+
+// Типы хранилища, которые можно указать в настройках storage.type
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+// Storage репозитории хранилища, выбранного в настройках
+type Storage struct {
+	Orders      Orders
+	DeadLetters DeadLetters
+	// DB соединение с Postgres; nil, если данные хранятся в памяти
+	DB *sql.DB
+}
+
+// Open открывает хранилище, выбранное в cfg.Storage.Type. Ошибка подключения к Postgres
+// возвращается как есть: хранилище в памяти выбирается только явно.
+func Open(cfg *config.Config) (*Storage, error) {
+	switch cfg.Storage.Type {
+	case StoragePostgres:
+		ordersRepo, err := New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{
+			Orders:      ordersRepo,
+			DeadLetters: NewDeadLettersRepo(ordersRepo.DB, cfg.DB.QueryTimeout),
+			DB:          ordersRepo.DB,
+		}, nil
+	case StorageMemory:
+		return &Storage{Orders: NewMemoryOrdersRepo(), DeadLetters: NewMemoryDeadLettersRepo()}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Storage.Type)
+	}
+}
+
+// Close закрывает соединение с БД; для хранилища в памяти ничего не делает
+func (s *Storage) Close() error {
+	if s.DB == nil {
+		return nil
+	}
+	return s.DB.Close()
+}
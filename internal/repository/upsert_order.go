@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/metrics"
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/repository/database"
+)
+
+// ErrStaleVersion возвращается UpsertOrder, если версия заказа не новее сохранённой
+var ErrStaleVersion = errors.New("stale order version")
+
+const (
+	lockOrderVersionQuery = "SELECT version, status, deleted_at IS NOT NULL FROM orders WHERE order_uid = $1 FOR UPDATE"
+	getStoredOrderQuery   = "SELECT " + orderColumns + " FROM orders WHERE order_uid = $1"
+	updateOrderQuery      = `UPDATE orders SET track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+    customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9, date_created = $10, oof_shard = $11, version = $12
+    WHERE order_uid = $1`
+	addOrderHistoryQuery   = `INSERT INTO order_history (order_uid, version, payload) VALUES ($1, $2, $3)`
+	getOrderHistoryQuery   = `SELECT version, payload, replaced_at FROM order_history WHERE order_uid = $1 ORDER BY version DESC`
+	activeOrderExistsQuery = "SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1 AND deleted_at IS NULL)"
+)
+
+// UpsertOrder сохраняет новый заказ или заменяет сохранённый более новой версией
+// и возвращает сохранённый заказ с итоговой версией.
+// Заказ без версии становится следующей версией после сохранённой; заказ с версией
+// не новее сохранённой отклоняется с ErrStaleVersion, мягко удалённый заказ — с ErrOrderDeleted.
+// Заменённая версия попадает в историю.
+// Статус заказа не меняется: новый заказ получает статус created, у сохранённого остаётся текущий.
+func (o *OrdersRepo) UpsertOrder(ctx context.Context, order models.Order) (*models.Order, error) {
+	defer metrics.ObserveDBQuery("UpsertOrder", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		// Блокируем строку заказа, чтобы параллельные обновления применялись по очереди
+		var (
+			current int64
+			deleted bool
+		)
+		err := tx.QueryRowContext(ctx, lockOrderVersionQuery, order.OrderUID).Scan(&current, &order.Status, &deleted)
+		if errors.Is(err, sql.ErrNoRows) {
+			order.Version = max(order.Version, 1)
+			order.Status = models.StatusCreated
+			return insertOrder(ctx, tx, order)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock order: %w", err)
+		}
+		if deleted {
+			return fmt.Errorf("order %s: %w", order.OrderUID, ErrOrderDeleted)
+		}
+
+		switch {
+		case order.Version == 0:
+			order.Version = current + 1
+		case order.Version <= current:
+			return fmt.Errorf("order %s version %d, stored version %d: %w",
+				order.OrderUID, order.Version, current, ErrStaleVersion)
+		}
+
+		if err := archiveOrder(ctx, tx, order.OrderUID); err != nil {
+			return err
+		}
+		return replaceOrder(ctx, tx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// archiveOrder сохраняет текущую версию заказа в историю
+func archiveOrder(ctx context.Context, tx *sql.Tx, orderUID string) error {
+	previous, err := scanOrder(tx.QueryRowContext(ctx, getStoredOrderQuery, orderUID))
+	if err != nil {
+		return fmt.Errorf("failed to get stored order: %w", err)
+	}
+	orders := []models.Order{*previous}
+	if err := populateOrderDetails(ctx, tx, orders); err != nil {
+		return fmt.Errorf("failed to populate stored order: %w", err)
+	}
+
+	payload, err := json.Marshal(orders[0])
+	if err != nil {
+		return fmt.Errorf("failed to marshal stored order: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, addOrderHistoryQuery, orderUID, previous.Version, payload); err != nil {
+		return fmt.Errorf("failed to archive order version %d: %w", previous.Version, err)
+	}
+	return nil
+}
+
+// replaceOrder записывает новую версию заказа поверх сохранённой
+func replaceOrder(ctx context.Context, tx *sql.Tx, order models.Order) error {
+	_, err := tx.ExecContext(ctx, updateOrderQuery, order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
+		order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey,
+		order.SmID, order.DateCreated, order.OofShard, order.Version)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	if err := database.UpsertPayment(ctx, tx, order.Payment, order.OrderUID); err != nil {
+		return err
+	}
+
+	// Состав заказа мог измениться, поэтому товары заменяются целиком
+	if err := database.DeleteItems(ctx, tx, order.OrderUID); err != nil {
+		return err
+	}
+	if err := database.AddItems(ctx, tx, order.Items, order.OrderUID); err != nil {
+		return fmt.Errorf("failed to insert items: %w", err)
+	}
+
+	if _, err := database.AddDelivery(ctx, tx, order.Delivery, order.OrderUID); err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+	return nil
+}
+
+// GetOrderHistory возвращает заменённые версии заказа, начиная с последней.
+// Для неизвестного или удалённого заказа возвращает ErrOrderNotFound.
+func (o *OrdersRepo) GetOrderHistory(ctx context.Context, orderUID string) ([]models.OrderVersion, error) {
+	defer metrics.ObserveDBQuery("GetOrderHistory", time.Now())
+	ctx, cancel := withTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	var exists bool
+	if err := o.DB.QueryRowContext(ctx, activeOrderExistsQuery, orderUID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check if order exists: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("order %s: %w", orderUID, ErrOrderNotFound)
+	}
+
+	rows, err := o.DB.QueryContext(ctx, getOrderHistoryQuery, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.OrderVersion{}
+	for rows.Next() {
+		var version models.OrderVersion
+		var payload []byte
+		if err := rows.Scan(&version.Version, &payload, &version.ReplacedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order version: %w", err)
+		}
+		if err := json.Unmarshal(payload, &version.Order); err != nil {
+			return nil, fmt.Errorf("failed to decode order version %d: %w", version.Version, err)
+		}
+		history = append(history, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iteration over rows failed: %w", err)
+	}
+	return history, nil
+}
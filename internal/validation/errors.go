@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ZnNr/WB-test-L0/internal/money"
+)
+
+// Коды ошибок проверки
+const (
+	CodeRequired = "required"
+	CodeFormat   = "format"
+	CodeRange    = "range"
+	CodeMismatch = "mismatch"
+)
+
+// FieldError ошибка в отдельном поле заказа; Field — путь к полю в JSON, например items[0].price
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors все ошибки, найденные при проверке заказа
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return "invalid order: " + strings.Join(messages, "; ")
+}
+
+// Fields возвращает пути полей с ошибками
+func (e Errors) Fields() []string {
+	fields := make([]string, len(e))
+	for i, fieldErr := range e {
+		fields[i] = fieldErr.Field
+	}
+	return fields
+}
+
+// collector накапливает ошибки проверки
+type collector struct {
+	errs Errors
+}
+
+func (c *collector) add(field, code, format string, args ...any) {
+	c.errs = append(c.errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *collector) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		c.add(field, CodeRequired, "is required")
+		return false
+	}
+	return true
+}
+
+// amount проверяет, что сумма не отрицательна и представима в минорных единицах валюты, если она известна
+func (c *collector) amount(field string, value money.Amount, currency *money.Currency) {
+	if value < 0 {
+		c.add(field, CodeRange, "must not be negative, got %s", value)
+		return
+	}
+	if currency != nil && !value.Fits(*currency) {
+		c.add(field, CodeFormat, "must have at most %d decimal places for %s, got %s", currency.Exponent, currency.Code, value)
+	}
+}
+
+func (c *collector) err() error {
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
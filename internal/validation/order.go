@@ -0,0 +1,129 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/money"
+)
+
+var (
+	emailPattern  = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s.]+$`)
+	phonePattern  = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+	zipPattern    = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z -]{1,8}[0-9A-Za-z]$`)
+	localePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+)
+
+// ValidateOrder проверяет заказ и возвращает Errors со всеми найденными ошибками или nil
+func ValidateOrder(order models.Order) error {
+	c := &collector{}
+
+	c.required("order_uid", order.OrderUID)
+	c.required("track_number", order.TrackNumber)
+	c.required("entry", order.Entry)
+	c.required("customer_id", order.CustomerID)
+	c.required("delivery_service", order.DeliveryService)
+	if order.DateCreated.IsZero() {
+		c.add("date_created", CodeRequired, "is required")
+	}
+	if c.required("locale", order.Locale) && !localePattern.MatchString(order.Locale) {
+		c.add("locale", CodeFormat, "must be a language code like en or ru-RU, got %q", order.Locale)
+	}
+	if order.SmID < 0 {
+		c.add("sm_id", CodeRange, "must not be negative, got %d", order.SmID)
+	}
+	if order.Version < 0 {
+		c.add("version", CodeRange, "must not be negative, got %d", order.Version)
+	}
+
+	validateDelivery(c, order.Delivery)
+	currency := validatePayment(c, order.Payment)
+	validateItems(c, order, currency)
+
+	return c.err()
+}
+
+func validateDelivery(c *collector, d models.Delivery) {
+	c.required("delivery.name", d.Name)
+	c.required("delivery.city", d.City)
+	c.required("delivery.address", d.Address)
+	if c.required("delivery.phone", d.Phone) && !phonePattern.MatchString(d.Phone) {
+		c.add("delivery.phone", CodeFormat, "must contain 7 to 15 digits with an optional leading +, got %q", d.Phone)
+	}
+	if c.required("delivery.zip", d.Zip) && !zipPattern.MatchString(d.Zip) {
+		c.add("delivery.zip", CodeFormat, "must be a postal code of 3 to 10 characters, got %q", d.Zip)
+	}
+	if c.required("delivery.email", d.Email) && !emailPattern.MatchString(d.Email) {
+		c.add("delivery.email", CodeFormat, "must be an email address, got %q", d.Email)
+	}
+}
+
+// validatePayment проверяет платёж и возвращает его валюту, если код валюты известен
+func validatePayment(c *collector, p models.Payment) *money.Currency {
+	c.required("payment.transaction", p.Transaction)
+	c.required("payment.provider", p.Provider)
+
+	var currency *money.Currency
+	if c.required("payment.currency", p.Currency) {
+		if found, ok := money.LookupCurrency(p.Currency); ok {
+			currency = &found
+		} else {
+			c.add("payment.currency", CodeFormat, "must be an ISO 4217 currency code, got %q", p.Currency)
+		}
+	}
+	if p.PaymentDT <= 0 {
+		c.add("payment.payment_dt", CodeRange, "must be a positive unix time, got %d", p.PaymentDT)
+	}
+	c.amount("payment.amount", p.Amount, currency)
+	c.amount("payment.delivery_cost", p.DeliveryCost, currency)
+	c.amount("payment.goods_total", p.GoodsTotal, currency)
+	c.amount("payment.custom_fee", p.CustomFee, currency)
+
+	if expected := p.GoodsTotal + p.DeliveryCost + p.CustomFee; p.Amount != expected {
+		c.add("payment.amount", CodeMismatch,
+			"must equal goods_total + delivery_cost + custom_fee = %s, got %s", expected, p.Amount)
+	}
+	return currency
+}
+
+func validateItems(c *collector, order models.Order, currency *money.Currency) {
+	if len(order.Items) == 0 {
+		c.add("items", CodeRequired, "must contain at least one item")
+		return
+	}
+
+	var goodsTotal money.Amount
+	for i, item := range order.Items {
+		field := func(name string) string { return fmt.Sprintf("items[%d].%s", i, name) }
+
+		c.required(field("rid"), item.Rid)
+		c.required(field("name"), item.Name)
+		if item.ChrtID <= 0 {
+			c.add(field("chrt_id"), CodeRange, "must be positive, got %d", item.ChrtID)
+		}
+		if item.NmID <= 0 {
+			c.add(field("nm_id"), CodeRange, "must be positive, got %d", item.NmID)
+		}
+		if item.TrackNumber != order.TrackNumber {
+			c.add(field("track_number"), CodeMismatch,
+				"must match the order track_number %q, got %q", order.TrackNumber, item.TrackNumber)
+		}
+
+		c.amount(field("price"), item.Price, currency)
+		c.amount(field("total_price"), item.TotalPrice, currency)
+		if item.Sale < 0 || item.Sale > 100 {
+			c.add(field("sale"), CodeRange, "must be a percentage between 0 and 100, got %v", item.Sale)
+		} else if expected := item.Price.Discount(item.Sale); (item.TotalPrice - expected).Abs() >= money.New(1) {
+			// Итоговая цена округляется до целых единиц, поэтому допускаем расхождение меньше единицы
+			c.add(field("total_price"), CodeMismatch,
+				"must equal price minus %v%% sale = %s, got %s", item.Sale, expected, item.TotalPrice)
+		}
+		goodsTotal += item.TotalPrice
+	}
+
+	if order.Payment.GoodsTotal != goodsTotal {
+		c.add("payment.goods_total", CodeMismatch,
+			"must equal the sum of item total prices %s, got %s", goodsTotal, order.Payment.GoodsTotal)
+	}
+}
@@ -0,0 +1,158 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ZnNr/WB-test-L0/internal/models"
+	"github.com/ZnNr/WB-test-L0/internal/money"
+	"github.com/ZnNr/WB-test-L0/internal/order_gen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       money.New(1817),
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: money.New(1500),
+			GoodsTotal:   money.New(317),
+		},
+		Items: []models.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       money.New(453),
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  money.New(317),
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+func validationErrors(t *testing.T, err error) Errors {
+	var errs Errors
+	require.True(t, errors.As(err, &errs), "expected validation.Errors, got %v", err)
+	return errs
+}
+
+// The reference order and generated orders pass validation
+func TestValidateOrderAcceptsValidOrders(t *testing.T) {
+	assert.NoError(t, ValidateOrder(validOrder()))
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, ValidateOrder(order_gen.GenerateOrder()))
+	}
+}
+
+// An empty order reports every missing field at once
+func TestValidateOrderReportsRequiredFields(t *testing.T) {
+	errs := validationErrors(t, ValidateOrder(models.Order{}))
+
+	fields := errs.Fields()
+	for _, field := range []string{"order_uid", "track_number", "locale", "delivery.email", "delivery.phone", "payment.currency", "items"} {
+		assert.Contains(t, fields, field)
+	}
+	for _, fieldErr := range errs {
+		if fieldErr.Field == "items" {
+			assert.Equal(t, CodeRequired, fieldErr.Code)
+		}
+	}
+}
+
+// Malformed contact data, currency and locale are rejected with format errors
+func TestValidateOrderChecksFormats(t *testing.T) {
+	for field, mutate := range map[string]func(*models.Order){
+		"delivery.email":   func(o *models.Order) { o.Delivery.Email = "test.gmail.com" },
+		"delivery.phone":   func(o *models.Order) { o.Delivery.Phone = "call me" },
+		"delivery.zip":     func(o *models.Order) { o.Delivery.Zip = "1" },
+		"payment.currency": func(o *models.Order) { o.Payment.Currency = "usd" },
+		"locale":           func(o *models.Order) { o.Locale = "english" },
+	} {
+		t.Run(field, func(t *testing.T) {
+			order := validOrder()
+			mutate(&order)
+
+			errs := validationErrors(t, ValidateOrder(order))
+
+			require.Len(t, errs, 1)
+			assert.Equal(t, field, errs[0].Field)
+			assert.Equal(t, CodeFormat, errs[0].Code)
+		})
+	}
+}
+
+// Prices, totals and track numbers must be consistent with each other
+func TestValidateOrderChecksConsistency(t *testing.T) {
+	for name, tc := range map[string]struct {
+		mutate func(*models.Order)
+		fields []string
+	}{
+		"item total price ignores sale": {
+			mutate: func(o *models.Order) { o.Items[0].TotalPrice = money.New(453) },
+			fields: []string{"items[0].total_price", "payment.goods_total"},
+		},
+		"goods total differs from items": {
+			mutate: func(o *models.Order) { o.Payment.GoodsTotal = money.New(300); o.Payment.Amount = money.New(1800) },
+			fields: []string{"payment.goods_total"},
+		},
+		"amount differs from totals": {
+			mutate: func(o *models.Order) { o.Payment.Amount = money.New(2000) },
+			fields: []string{"payment.amount"},
+		},
+		"amount finer than currency allows": {
+			mutate: func(o *models.Order) {
+				o.Payment.Currency = "JPY"
+				o.Payment.DeliveryCost = money.MustParse("1499.5")
+				o.Payment.Amount = money.MustParse("1816.5")
+			},
+			fields: []string{"payment.delivery_cost", "payment.amount"},
+		},
+		"item from another shipment": {
+			mutate: func(o *models.Order) { o.Items[0].TrackNumber = "OTHERTRACK" },
+			fields: []string{"items[0].track_number"},
+		},
+		"negative price": {
+			mutate: func(o *models.Order) { o.Items[0].Price = money.New(-453) },
+			fields: []string{"items[0].price", "items[0].total_price"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			order := validOrder()
+			tc.mutate(&order)
+
+			errs := validationErrors(t, ValidateOrder(order))
+
+			assert.ElementsMatch(t, tc.fields, errs.Fields())
+			assert.Contains(t, errs.Error(), tc.fields[0])
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Embedded migrations are paired, ordered and start with the initial schema
+func TestEmbeddedMigrationsAreOrdered(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, "sql")
+
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "init", migrations[0].Name)
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+	}
+}
+
+// The initial migration must never drop existing data on upgrade
+func TestInitialMigrationIsNotDestructive(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, "sql")
+
+	require.NoError(t, err)
+	assert.NotContains(t, migrations[0].Up, "DROP TABLE")
+}
+
+// A migration without a down script is rejected
+func TestLoadMigrationsRequiresDownScript(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0001_init.up.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+	}
+
+	_, err := loadMigrations(fsys, "sql")
+
+	assert.Error(t, err)
+}
+
+// Files that don't follow the naming scheme are rejected
+func TestLoadMigrationsRejectsInvalidNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/init.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+	}
+
+	_, err := loadMigrations(fsys, "sql")
+
+	assert.Error(t, err)
+}
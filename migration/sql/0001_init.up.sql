@@ -1,5 +1,4 @@
 --Таблица заказов (orders)
-DROP TABLE IF EXISTS orders cascade;
 CREATE TABLE IF NOT EXISTS orders
 (
     order_uid          VARCHAR(255) PRIMARY KEY not null,
@@ -62,4 +61,3 @@ CREATE TABLE IF NOT EXISTS items
     brand        VARCHAR(100),
     status       INTEGER
 );
-
@@ -0,0 +1,15 @@
+--Таблица сообщений, отправленных в dead-letter топик (dead_letters)
+CREATE TABLE IF NOT EXISTS dead_letters
+(
+    id          BIGSERIAL PRIMARY KEY,
+    stage       VARCHAR(50)  NOT NULL,
+    error       TEXT         NOT NULL,
+    topic       VARCHAR(255) NOT NULL,
+    partition   INTEGER      NOT NULL,
+    "offset"    BIGINT       NOT NULL,
+    attempts    INTEGER      NOT NULL,
+    key         BYTEA,
+    payload     BYTEA,
+    failed_at   TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    redriven_at TIMESTAMPTZ
+);
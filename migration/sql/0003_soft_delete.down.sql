@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS orders_active_idx;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS deleted_at;
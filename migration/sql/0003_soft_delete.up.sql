@@ -0,0 +1,5 @@
+--Мягкое удаление заказов: удалённый заказ остаётся в БД и может быть восстановлен
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS orders_active_idx ON orders (order_uid) WHERE deleted_at IS NULL;
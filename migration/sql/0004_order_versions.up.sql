@@ -0,0 +1,13 @@
+--Версия заказа: каждое применённое изменение увеличивает её
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+--История заказов (order_history): снимки версий, заменённых более новыми
+CREATE TABLE IF NOT EXISTS order_history
+(
+    order_uid   VARCHAR(255) NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    version     BIGINT       NOT NULL,
+    payload     JSONB        NOT NULL,
+    replaced_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    PRIMARY KEY (order_uid, version)
+);
@@ -0,0 +1,10 @@
+--Возврат к прежним типам округляет суммы до их точности
+ALTER TABLE items
+    ALTER COLUMN price TYPE DECIMAL(10, 2) USING round(price, 2),
+    ALTER COLUMN total_price TYPE DECIMAL(10, 2) USING round(total_price, 2);
+
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE INTEGER USING round(amount)::INTEGER,
+    ALTER COLUMN delivery_cost TYPE DECIMAL(10, 2) USING round(delivery_cost, 2),
+    ALTER COLUMN goods_total TYPE DECIMAL(10, 2) USING round(goods_total, 2),
+    ALTER COLUMN custom_fee TYPE DECIMAL(10, 2) USING round(custom_fee, 2);
@@ -0,0 +1,11 @@
+--Денежные суммы хранятся точно: NUMERIC(19,4) вмещает прежние INTEGER и DECIMAL(10,2) без потерь
+--и любую валюту ISO 4217, включая валюты с тремя знаками минорной единицы
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE NUMERIC(19, 4) USING amount::NUMERIC(19, 4),
+    ALTER COLUMN delivery_cost TYPE NUMERIC(19, 4) USING delivery_cost::NUMERIC(19, 4),
+    ALTER COLUMN goods_total TYPE NUMERIC(19, 4) USING goods_total::NUMERIC(19, 4),
+    ALTER COLUMN custom_fee TYPE NUMERIC(19, 4) USING custom_fee::NUMERIC(19, 4);
+
+ALTER TABLE items
+    ALTER COLUMN price TYPE NUMERIC(19, 4) USING price::NUMERIC(19, 4),
+    ALTER COLUMN total_price TYPE NUMERIC(19, 4) USING total_price::NUMERIC(19, 4);
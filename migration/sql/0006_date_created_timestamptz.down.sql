@@ -0,0 +1,2 @@
+ALTER TABLE orders
+    ALTER COLUMN date_created TYPE TIMESTAMP USING date_created AT TIME ZONE 'UTC';
@@ -0,0 +1,3 @@
+--Дата создания заказа хранится с часовым поясом; прежние значения без пояса записывались в UTC
+ALTER TABLE orders
+    ALTER COLUMN date_created TYPE TIMESTAMPTZ USING date_created AT TIME ZONE 'UTC';
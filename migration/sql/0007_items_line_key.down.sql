@@ -0,0 +1,7 @@
+--Прежний ключ допускает одну строку на chrt_id; откат не выполнится, если chrt_id повторяется
+DROP INDEX IF EXISTS items_chrt_id_idx;
+
+ALTER TABLE items
+    DROP CONSTRAINT IF EXISTS items_pkey,
+    ADD PRIMARY KEY (chrt_id),
+    DROP COLUMN IF EXISTS line_no;
@@ -0,0 +1,18 @@
+--Товар заказа идентифицируется номером строки в заказе: один chrt_id может встречаться в разных заказах
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS line_no INTEGER;
+
+--Существующие строки нумеруются внутри заказа; chrt_id до этой миграции уникален, поэтому соответствие однозначно
+UPDATE items i
+SET line_no = numbered.line_no
+FROM (SELECT order_uid, chrt_id, row_number() OVER (PARTITION BY order_uid ORDER BY chrt_id) AS line_no
+      FROM items) numbered
+WHERE i.order_uid = numbered.order_uid
+  AND i.chrt_id = numbered.chrt_id;
+
+ALTER TABLE items
+    ALTER COLUMN line_no SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS items_pkey,
+    ADD PRIMARY KEY (order_uid, line_no);
+
+CREATE INDEX IF NOT EXISTS items_chrt_id_idx ON items (chrt_id);
@@ -0,0 +1,3 @@
+--Заголовки исходного сообщения: без них повторная отправка теряет атрибуты события CloudEvents в binary-режиме
+ALTER TABLE dead_letters
+    ADD COLUMN IF NOT EXISTS headers JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS order_status_history;
+
+DROP INDEX IF EXISTS orders_status_idx;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS status;
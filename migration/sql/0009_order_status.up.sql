@@ -0,0 +1,24 @@
+--Статус заказа в жизненном цикле; допустимые переходы проверяет приложение
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'created'
+        CONSTRAINT orders_status_check
+            CHECK (status IN ('created', 'paid', 'assembled', 'shipped', 'delivered', 'cancelled', 'returned'));
+
+CREATE INDEX IF NOT EXISTS orders_status_idx ON orders (status) WHERE deleted_at IS NULL;
+
+--История статусов (order_status_history): время каждого перехода, по порядку id
+CREATE TABLE IF NOT EXISTS order_status_history
+(
+    id         BIGSERIAL PRIMARY KEY,
+    order_uid  VARCHAR(255) NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    status     VARCHAR(20)  NOT NULL,
+    changed_at TIMESTAMPTZ  NOT NULL,
+    reason     TEXT
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_idx ON order_status_history (order_uid, id);
+
+--История существующих заказов начинается со статуса created в момент создания заказа
+INSERT INTO order_status_history (order_uid, status, changed_at)
+SELECT order_uid, 'created', COALESCE(date_created, now())
+FROM orders;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS dead_letters_source_idx;
@@ -0,0 +1,10 @@
+--Сообщение исходного топика попадает в карантин один раз: повторная отправка в dead-letter топик не дублирует строку
+DELETE
+FROM dead_letters d
+    USING dead_letters earlier
+WHERE d.topic = earlier.topic
+  AND d.partition = earlier.partition
+  AND d."offset" = earlier."offset"
+  AND d.id > earlier.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS dead_letters_source_idx ON dead_letters (topic, partition, "offset");